@@ -2,13 +2,26 @@ package main
 
 import (
 	"log"
+	"os"
 
+	"github.com/shuhrat-shokirov/wallet/pkg/vault"
 	"github.com/shuhrat-shokirov/wallet/pkg/wallet"
 )
 
 func main() {
 	s := wallet.Service{}
 
+	key := os.Getenv("WALLET_VAULT_KEY")
+	if key != "" {
+		v, err := vault.New([]byte(key))
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		s.SetVault(v)
+	}
+
 	_, err := s.RegisterAccount("+992935626274")
 	if err != nil {
 		log.Println(err)
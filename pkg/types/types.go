@@ -1,39 +1,195 @@
 package types
 
+import "time"
+
 type Money int64
 
 type PaymentCategory string
 
+const (
+	PaymentCategoryCardDeposit   PaymentCategory = "card_deposit"
+	PaymentCategoryAccountToCard PaymentCategory = "account_to_card"
+	PaymentCategoryCardToCard    PaymentCategory = "card_to_card"
+	PaymentCategoryTransferOut   PaymentCategory = "transfer_out"
+	PaymentCategoryTransferIn    PaymentCategory = "transfer_in"
+	PaymentCategoryOverdraftFee  PaymentCategory = "overdraft_fee"
+	PaymentCategoryRefund        PaymentCategory = "refund"
+)
+
 type PaymentStatus string
 
 const (
 	PaymentStatusOk         PaymentStatus = "OK"
 	PaymentStatusFail       PaymentStatus = "FAIL"
 	PaymentStatusInProgress PaymentStatus = "INPROGRESS"
+	PaymentStatusAuthorized PaymentStatus = "AUTHORIZED"
+	PaymentStatusVoided     PaymentStatus = "VOIDED"
+	PaymentStatusRefunded   PaymentStatus = "REFUNDED"
 )
 
+type PaymentTransition struct {
+	From PaymentStatus
+	To   PaymentStatus
+	At   time.Time
+}
+
 type Payment struct {
-	ID        string
-	AccountID int64
-	Amount    Money
-	Category  PaymentCategory
-	Status    PaymentStatus
+	ID          string
+	AccountID   int64
+	Amount      Money
+	Category    PaymentCategory
+	Status      PaymentStatus
+	CardID      int64
+	ToAccountID int64
+	ToCardID    int64
+	LinkedID    string
+	RefundOf    string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Details     PaymentDetails
+	Authorized  Money
+}
+
+type PaymentDetails struct {
+	Description string
+	Merchant    string
+	Metadata    map[string]string
 }
 
 type Phone string
 
+type AccountStatus string
+
+const (
+	AccountStatusActive AccountStatus = "ACTIVE"
+	AccountStatusFrozen AccountStatus = "FROZEN"
+	AccountStatusClosed AccountStatus = "CLOSED"
+)
+
+type KYCLevel string
+
+const (
+	KYCLevelAnonymous KYCLevel = "ANONYMOUS"
+	KYCLevelBasic     KYCLevel = "BASIC"
+	KYCLevelFull      KYCLevel = "FULL"
+)
+
 type Account struct {
-	ID      int64
-	Phone   Phone
-	Balance Money
+	ID            int64
+	Phone         Phone
+	Balance       Money
+	Held          Money
+	Status        AccountStatus
+	KYCLevel      KYCLevel
+	VerifiedBy    string
+	CreditLimit   Money
+	OverdraftRate int64
+	Pockets       map[string]Money
+}
+
+type Debt struct {
+	AccountID   int64
+	Phone       Phone
+	Debt        Money
+	CreditLimit Money
+}
+
+type Claim struct {
+	ID            string
+	PaymentID     string
+	FromAccountID int64
+	Phone         Phone
+	Amount        Money
 }
 
 type Favorite struct {
+	ID         string
+	AccountID  int64
+	Name       string
+	Amount     Money
+	Category   PaymentCategory
+	Details    PaymentDetails
+	Recurrence *Recurrence
+	Template   *FavoriteTemplate
+}
+
+type FavoriteTemplate struct {
+	Payee     string
+	Reference string
+	MinAmount Money
+	MaxAmount Money
+}
+
+type FavoriteParams struct {
+	Amount      Money
+	Reference   string
+	Description string
+}
+
+type RecurrenceFrequency string
+
+const (
+	RecurrenceDaily   RecurrenceFrequency = "DAILY"
+	RecurrenceWeekly  RecurrenceFrequency = "WEEKLY"
+	RecurrenceMonthly RecurrenceFrequency = "MONTHLY"
+	RecurrenceCron    RecurrenceFrequency = "CRON"
+)
+
+type RecurrencePolicy string
+
+const (
+	RecurrencePolicySkip  RecurrencePolicy = "SKIP"
+	RecurrencePolicyRetry RecurrencePolicy = "RETRY"
+)
+
+type Recurrence struct {
+	Frequency     RecurrenceFrequency
+	Weekday       time.Weekday
+	DayOfMonth    int
+	Cron          string
+	Policy        RecurrencePolicy
+	MaxRetries    int
+	RetryInterval time.Duration
+	NextRunAt     time.Time
+	Retries       int
+}
+
+type RecurringRunStatus string
+
+const (
+	RecurringRunOk      RecurringRunStatus = "OK"
+	RecurringRunRetry   RecurringRunStatus = "RETRY"
+	RecurringRunSkipped RecurringRunStatus = "SKIPPED"
+	RecurringRunFail    RecurringRunStatus = "FAIL"
+)
+
+type RecurringRun struct {
+	FavoriteID  string
+	ScheduledAt time.Time
+	RanAt       time.Time
+	Status      RecurringRunStatus
+	PaymentID   string
+	Error       string
+}
+
+type ScheduleStatus string
+
+const (
+	ScheduleStatusPending   ScheduleStatus = "PENDING"
+	ScheduleStatusExecuted  ScheduleStatus = "EXECUTED"
+	ScheduleStatusFailed    ScheduleStatus = "FAILED"
+	ScheduleStatusCancelled ScheduleStatus = "CANCELLED"
+)
+
+type ScheduledPayment struct {
 	ID        string
 	AccountID int64
-	Name      string
 	Amount    Money
 	Category  PaymentCategory
+	ExecuteAt time.Time
+	Status    ScheduleStatus
+	PaymentID string
+	Error     string
 }
 
 type Progress struct {
@@ -41,11 +197,25 @@ type Progress struct {
 	Result Money
 }
 
+type CardStatus string
+
+const (
+	CardStatusActive  CardStatus = "ACTIVE"
+	CardStatusFrozen  CardStatus = "FROZEN"
+	CardStatusBlocked CardStatus = "BLOCKED"
+	CardStatusExpired CardStatus = "EXPIRED"
+)
+
 type Card struct {
-	ID       int64
-	Issuer   string
-	Balance  Money
-	Currency string
-	Number   string
-	PAN      string
+	ID             int64
+	AccountID      int64
+	Issuer         string
+	Balance        Money
+	Currency       string
+	Number         string
+	Token          string
+	Status         CardStatus
+	ExpiresAt      time.Time
+	DailyLimit     Money
+	CategoryLimits map[PaymentCategory]Money
 }
@@ -0,0 +1,204 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidKey    = errors.New("vault key must be 16, 24 or 32 bytes")
+	ErrTokenNotFound = errors.New("token not found")
+	ErrEmptyActor    = errors.New("actor is required to detokenize")
+	ErrInvalidDump   = errors.New("invalid vault dump")
+)
+
+const tokenPrefix = "tok_"
+
+type AuditRecord struct {
+	Token  string
+	Actor  string
+	Reason string
+	At     time.Time
+}
+
+type Vault struct {
+	mu      sync.Mutex
+	aead    cipher.AEAD
+	entries map[string]string
+	audit   []AuditRecord
+	clock   func() time.Time
+}
+
+func New(key []byte) (*Vault, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, ErrInvalidKey
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Vault{
+		aead:    aead,
+		entries: make(map[string]string),
+	}, nil
+}
+
+func (v *Vault) SetClock(clock func() time.Time) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.clock = clock
+}
+
+func (v *Vault) Tokenize(pan string) (string, error) {
+	nonce := make([]byte, v.aead.NonceSize())
+	_, err := io.ReadFull(rand.Reader, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := v.aead.Seal(nonce, nonce, []byte(pan), nil)
+	token := tokenPrefix + uuid.New().String()
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.entries[token] = base64.StdEncoding.EncodeToString(sealed)
+
+	return token, nil
+}
+
+func (v *Vault) Detokenize(token string, actor string, reason string) (string, error) {
+	if actor == "" {
+		return "", ErrEmptyActor
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	encoded, ok := v.entries[token]
+	if !ok {
+		return "", ErrTokenNotFound
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	nonceSize := v.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return "", ErrTokenNotFound
+	}
+
+	pan, err := v.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", err
+	}
+
+	record := AuditRecord{
+		Token:  token,
+		Actor:  actor,
+		Reason: reason,
+		At:     v.now(),
+	}
+	v.audit = append(v.audit, record)
+	log.Printf("vault: token %s detokenized by %s: %s", token, actor, reason)
+
+	return string(pan), nil
+}
+
+func (v *Vault) AuditLog() []AuditRecord {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	records := make([]AuditRecord, len(v.audit))
+	copy(records, v.audit)
+
+	return records
+}
+
+func (v *Vault) Export(path string) error {
+	v.mu.Lock()
+	result := ""
+	for token, encoded := range v.entries {
+		result += token + ";" + encoded + "\n"
+	}
+	v.mu.Unlock()
+
+	file, err := os.Create(path)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	defer func() {
+		err = file.Close()
+		if err != nil {
+			log.Println(err)
+		}
+	}()
+
+	_, err = file.WriteString(result)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+func (v *Vault) Import(path string) error {
+	byteData, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	for _, split := range strings.Split(string(byteData), "\n") {
+		if len(split) == 0 {
+			break
+		}
+
+		data := strings.Split(split, ";")
+		if len(data) != 2 {
+			return ErrInvalidDump
+		}
+
+		v.entries[data[0]] = data[1]
+	}
+
+	return nil
+}
+
+func (v *Vault) now() time.Time {
+	if v.clock != nil {
+		return v.clock()
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,147 @@
+package vault
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("0123456789abcdef")
+
+func TestNew_invalidKey(t *testing.T) {
+	_, err := New([]byte("short"))
+	if err != ErrInvalidKey {
+		t.Error(err)
+	}
+}
+
+func TestVault_TokenizeDetokenize(t *testing.T) {
+	v, err := New(testKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+	v.SetClock(func() time.Time {
+		return now
+	})
+
+	token, err := v.Tokenize("4111111111111111")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !strings.HasPrefix(token, tokenPrefix) || strings.Contains(token, "4111") {
+		t.Errorf("invalid token, got %v", token)
+	}
+
+	_, err = v.Detokenize(token, "", "no actor")
+	if err != ErrEmptyActor {
+		t.Error(err)
+	}
+
+	_, err = v.Detokenize("tok_unknown", "support", "unknown")
+	if err != ErrTokenNotFound {
+		t.Error(err)
+	}
+
+	pan, err := v.Detokenize(token, "support", "chargeback")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if pan != "4111111111111111" {
+		t.Errorf("invalid pan, got %v", pan)
+	}
+
+	audit := v.AuditLog()
+	if len(audit) != 1 {
+		t.Errorf("invalid audit, got %v", audit)
+		return
+	}
+
+	if audit[0].Token != token || audit[0].Actor != "support" || !audit[0].At.Equal(now) {
+		t.Errorf("invalid audit record, got %v", audit[0])
+	}
+}
+
+func TestVault_ExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	v, err := New(testKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	token, err := v.Tokenize("4111111111111111")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = v.Export(dir + "/vault.dump")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	dump, err := ioutil.ReadFile(dir + "/vault.dump")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if strings.Contains(string(dump), "4111111111111111") {
+		t.Error("dump must not contain raw pan")
+	}
+
+	restored, err := New(testKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = restored.Import(dir + "/vault.dump")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	pan, err := restored.Detokenize(token, "support", "restore")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if pan != "4111111111111111" {
+		t.Errorf("invalid pan, got %v", pan)
+	}
+
+	other, err := New([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = other.Import(dir + "/vault.dump")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = other.Detokenize(token, "support", "wrong key")
+	if err == nil {
+		t.Error("detokenize with wrong key must fail")
+	}
+}
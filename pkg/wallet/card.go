@@ -0,0 +1,730 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+const (
+	IssuerVisa       = "VISA"
+	IssuerMastercard = "MASTERCARD"
+	IssuerAmex       = "AMEX"
+	IssuerUnionPay   = "UNIONPAY"
+	IssuerMir        = "MIR"
+)
+
+const cardValidityYears = 3
+
+type cardSpending struct {
+	day        time.Time
+	total      types.Money
+	byCategory map[types.PaymentCategory]types.Money
+}
+
+type issuerRule struct {
+	issuer  string
+	from    int
+	to      int
+	lengths []int
+}
+
+var issuerRules = []issuerRule{
+	{issuer: IssuerVisa, from: 4000, to: 4999, lengths: []int{13, 16, 19}},
+	{issuer: IssuerMastercard, from: 5100, to: 5599, lengths: []int{16}},
+	{issuer: IssuerMastercard, from: 2221, to: 2720, lengths: []int{16}},
+	{issuer: IssuerAmex, from: 3400, to: 3499, lengths: []int{15}},
+	{issuer: IssuerAmex, from: 3700, to: 3799, lengths: []int{15}},
+	{issuer: IssuerMir, from: 2200, to: 2204, lengths: []int{16, 17, 18, 19}},
+	{issuer: IssuerUnionPay, from: 6200, to: 6299, lengths: []int{16, 17, 18, 19}},
+}
+
+func NormalizePAN(pan string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(pan)
+}
+
+func ValidatePAN(pan string) error {
+	if len(pan) < 12 || len(pan) > 19 {
+		return ErrInvalidPANLength
+	}
+
+	for _, digit := range pan {
+		if digit < '0' || digit > '9' {
+			return ErrInvalidPAN
+		}
+	}
+
+	rule, ok := findIssuerRule(pan)
+	if !ok {
+		return ErrUnknownIssuer
+	}
+
+	validLength := false
+	for _, length := range rule.lengths {
+		if len(pan) == length {
+			validLength = true
+		}
+	}
+
+	if !validLength {
+		return ErrInvalidPANLength
+	}
+
+	if !luhn(pan) {
+		return ErrInvalidPAN
+	}
+
+	return nil
+}
+
+func DetectIssuer(pan string) string {
+	rule, ok := findIssuerRule(pan)
+	if !ok {
+		return ""
+	}
+
+	return rule.issuer
+}
+
+func MaskPAN(pan string) string {
+	if len(pan) <= 8 {
+		return strings.Repeat("*", len(pan))
+	}
+
+	return pan[:4] + strings.Repeat("*", len(pan)-8) + pan[len(pan)-4:]
+}
+
+func findIssuerRule(pan string) (issuerRule, bool) {
+	if len(pan) < 4 {
+		return issuerRule{}, false
+	}
+
+	bin, err := strconv.Atoi(pan[:4])
+	if err != nil {
+		return issuerRule{}, false
+	}
+
+	for _, rule := range issuerRules {
+		if bin >= rule.from && bin <= rule.to {
+			return rule, true
+		}
+	}
+
+	return issuerRule{}, false
+}
+
+func luhn(pan string) bool {
+	sum := 0
+	double := false
+	for i := len(pan) - 1; i >= 0; i-- {
+		digit := int(pan[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+func (s *Service) IssueCard(accountID int64, pan string, currency string) (*types.Card, error) {
+	pan = NormalizePAN(pan)
+
+	err := ValidatePAN(pan)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.vault == nil {
+		return nil, ErrVaultNotConfigured
+	}
+
+	token, err := s.vault.Tokenize(pan)
+	if err != nil {
+		return nil, err
+	}
+
+	s.nextCardID++
+
+	now := s.now()
+	expiresAt := time.Date(now.Year()+cardValidityYears, now.Month()+1, 1, 0, 0, 0, 0, now.Location()).Add(-time.Nanosecond)
+
+	card := &types.Card{
+		ID:        s.nextCardID,
+		AccountID: account.ID,
+		Issuer:    DetectIssuer(pan),
+		Balance:   0,
+		Currency:  currency,
+		Number:    MaskPAN(pan),
+		Token:     token,
+		Status:    types.CardStatusActive,
+		ExpiresAt: expiresAt,
+	}
+
+	s.addCard(card)
+
+	return card, nil
+}
+
+func (s *Service) FindCardByID(cardID int64) (*types.Card, error) {
+	card, ok := s.cardsByID[cardID]
+	if !ok {
+		return nil, ErrCardNotFound
+	}
+
+	return card, nil
+}
+
+func (s *Service) ListCardsByAccount(accountID int64) ([]*types.Card, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	cards := append([]*types.Card{}, s.cardsByAccount[accountID]...)
+
+	if len(cards) == 0 {
+		return nil, ErrCardNotFound
+	}
+
+	return cards, nil
+}
+
+func (s *Service) DetokenizeCard(cardID int64, actor string, reason string) (string, error) {
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return "", err
+	}
+
+	if s.vault == nil {
+		return "", ErrVaultNotConfigured
+	}
+
+	return s.vault.Detokenize(card.Token, actor, reason)
+}
+
+func (s *Service) FreezeCard(cardID int64) error {
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkCard(card)
+	if err != nil {
+		return err
+	}
+
+	card.Status = types.CardStatusFrozen
+
+	return nil
+}
+
+func (s *Service) UnfreezeCard(cardID int64) error {
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return err
+	}
+
+	if card.Status != types.CardStatusFrozen {
+		return s.checkCard(card)
+	}
+
+	card.Status = types.CardStatusActive
+
+	return s.checkCard(card)
+}
+
+func (s *Service) BlockCard(cardID int64) error {
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return err
+	}
+
+	card.Status = types.CardStatusBlocked
+
+	return nil
+}
+
+func (s *Service) ExpireCard(cardID int64) error {
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return err
+	}
+
+	if card.Status == types.CardStatusBlocked {
+		return ErrCardBlocked
+	}
+
+	card.Status = types.CardStatusExpired
+
+	return nil
+}
+
+func (s *Service) checkCard(card *types.Card) error {
+	if card.Status == types.CardStatusActive && !card.ExpiresAt.IsZero() && s.now().After(card.ExpiresAt) {
+		card.Status = types.CardStatusExpired
+	}
+
+	switch card.Status {
+	case types.CardStatusFrozen:
+		return ErrCardFrozen
+	case types.CardStatusBlocked:
+		return ErrCardBlocked
+	case types.CardStatusExpired:
+		return ErrCardExpired
+	}
+
+	return nil
+}
+
+func (s *Service) SetCardDailyLimit(cardID int64, limit types.Money) error {
+	if limit < 0 {
+		return ErrAmountMustBePositive
+	}
+
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return err
+	}
+
+	card.DailyLimit = limit
+
+	return nil
+}
+
+func (s *Service) SetCardCategoryLimit(cardID int64, category types.PaymentCategory, limit types.Money) error {
+	if limit < 0 {
+		return ErrAmountMustBePositive
+	}
+
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return err
+	}
+
+	if limit == 0 {
+		delete(card.CategoryLimits, category)
+		return nil
+	}
+
+	if card.CategoryLimits == nil {
+		card.CategoryLimits = make(map[types.PaymentCategory]types.Money)
+	}
+	card.CategoryLimits[category] = limit
+
+	return nil
+}
+
+func (s *Service) PayByCard(cardID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkCardOwner(card)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.debitCard(card, amount, category)
+	if err != nil {
+		return nil, err
+	}
+
+	payment := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: card.AccountID,
+		Amount:    amount,
+		Category:  category,
+		Status:    types.PaymentStatusInProgress,
+		CardID:    card.ID,
+	}
+
+	s.addPayment(payment)
+
+	return payment, nil
+}
+
+func (s *Service) checkCardOwner(card *types.Card) error {
+	account, err := s.FindAccountByID(card.AccountID)
+	if err != nil {
+		return err
+	}
+
+	return checkAccount(account)
+}
+
+func (s *Service) debitCard(card *types.Card, amount types.Money, category types.PaymentCategory) error {
+	err := s.checkCard(card)
+	if err != nil {
+		return err
+	}
+
+	spending := s.cardSpendingFor(card.ID)
+
+	if card.DailyLimit > 0 && spending.total+amount > card.DailyLimit {
+		return ErrCardDailyLimitExceeded
+	}
+
+	limit, ok := card.CategoryLimits[category]
+	if ok && spending.byCategory[category]+amount > limit {
+		return ErrCardCategoryLimitExceeded
+	}
+
+	if card.Balance < amount {
+		return ErrNotEnoughCardBalance
+	}
+
+	card.Balance -= amount
+	spending.total += amount
+	spending.byCategory[category] += amount
+
+	return nil
+}
+
+func (s *Service) cardSpendingFor(cardID int64) *cardSpending {
+	now := s.now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	if s.cardSpendings == nil {
+		s.cardSpendings = make(map[int64]*cardSpending)
+	}
+
+	spending, ok := s.cardSpendings[cardID]
+	if !ok || !spending.day.Equal(day) {
+		spending = &cardSpending{
+			day:        day,
+			byCategory: make(map[types.PaymentCategory]types.Money),
+		}
+		s.cardSpendings[cardID] = spending
+	}
+
+	return spending
+}
+
+func (s *Service) DepositFromCard(accountID int64, cardID int64, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if card.AccountID != account.ID {
+		return nil, ErrCardNotLinked
+	}
+
+	err = s.checkDepositLimits(account, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.debitCard(card, amount, types.PaymentCategoryCardDeposit)
+	if err != nil {
+		return nil, err
+	}
+
+	account.Balance += amount
+	s.addTurnover(account.ID, amount)
+
+	payment := &types.Payment{
+		ID:          uuid.New().String(),
+		AccountID:   account.ID,
+		Amount:      amount,
+		Category:    types.PaymentCategoryCardDeposit,
+		Status:      types.PaymentStatusInProgress,
+		CardID:      card.ID,
+		ToAccountID: account.ID,
+	}
+
+	s.addPayment(payment)
+
+	return payment, nil
+}
+
+func (s *Service) TransferToCard(accountID int64, cardID int64, amount types.Money) (*types.Payment, error) {
+	return s.transferToCard(accountID, cardID, amount, true)
+}
+
+func (s *Service) transferToCard(accountID int64, cardID int64, amount types.Money, checkLimits bool) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	card, err := s.FindCardByID(cardID)
+	if err != nil {
+		return nil, err
+	}
+
+	if card.AccountID != account.ID {
+		return nil, ErrCardNotLinked
+	}
+
+	err = s.checkCard(card)
+	if err != nil {
+		return nil, err
+	}
+
+	if checkLimits {
+		err = s.checkPaymentLimits(account, amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if availableBalance(account) < amount {
+		return nil, ErrNotEnoughBalance
+	}
+
+	account.Balance -= amount
+	card.Balance += amount
+	s.addTurnover(account.ID, amount)
+
+	payment := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Amount:    amount,
+		Category:  types.PaymentCategoryAccountToCard,
+		Status:    types.PaymentStatusInProgress,
+		ToCardID:  card.ID,
+	}
+
+	s.addPayment(payment)
+
+	return payment, nil
+}
+
+func (s *Service) TransferCardToCard(fromCardID int64, toCardID int64, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	if fromCardID == toCardID {
+		return nil, ErrSameCard
+	}
+
+	from, err := s.FindCardByID(fromCardID)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.FindCardByID(toCardID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkCardOwner(from)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkCardOwner(to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkCard(to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.debitCard(from, amount, types.PaymentCategoryCardToCard)
+	if err != nil {
+		return nil, err
+	}
+
+	to.Balance += amount
+
+	payment := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: from.AccountID,
+		Amount:    amount,
+		Category:  types.PaymentCategoryCardToCard,
+		Status:    types.PaymentStatusInProgress,
+		CardID:    from.ID,
+		ToCardID:  to.ID,
+	}
+
+	s.addPayment(payment)
+
+	return payment, nil
+}
+
+func (s *Service) actionByCards(path string) error {
+	byteData, err := ioutil.ReadFile(path)
+	if err == nil {
+		datas := string(byteData)
+		splits := strings.Split(datas, "\n")
+
+		for _, split := range splits {
+			if len(split) == 0 {
+				break
+			}
+
+			data := strings.Split(split, ";")
+
+			id, err := strconv.Atoi(data[0])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			accountID, err := strconv.Atoi(data[1])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			issuer := data[2]
+
+			balance, err := strconv.Atoi(data[3])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			currency := data[4]
+			number := data[5]
+			token := data[6]
+
+			status := types.CardStatusActive
+			var expiresAt int64
+			if len(data) > 8 {
+				status = types.CardStatus(data[7])
+
+				expiresAt, err = strconv.ParseInt(data[8], 10, 64)
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
+			dailyLimit := 0
+			var categoryLimits map[types.PaymentCategory]types.Money
+			if len(data) > 10 {
+				dailyLimit, err = strconv.Atoi(data[9])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+
+				categoryLimits, err = parseCategoryLimits(data[10])
+				if err != nil {
+					log.Println("can't parse category limits")
+					return err
+				}
+			}
+
+			card, err := s.FindCardByID(int64(id))
+			if err != nil {
+				newCard := &types.Card{
+					ID:             int64(id),
+					AccountID:      int64(accountID),
+					Issuer:         issuer,
+					Balance:        types.Money(balance),
+					Currency:       currency,
+					Number:         number,
+					Token:          token,
+					Status:         status,
+					ExpiresAt:      timeOrZero(expiresAt),
+					DailyLimit:     types.Money(dailyLimit),
+					CategoryLimits: categoryLimits,
+				}
+
+				s.addCard(newCard)
+			} else {
+				s.setCardAccount(card, int64(accountID))
+				card.Issuer = issuer
+				card.Balance = types.Money(balance)
+				card.Currency = currency
+				card.Number = number
+				card.Token = token
+				card.Status = status
+				card.ExpiresAt = timeOrZero(expiresAt)
+				card.DailyLimit = types.Money(dailyLimit)
+				card.CategoryLimits = categoryLimits
+			}
+
+			if int64(id) > s.nextCardID {
+				s.nextCardID = int64(id)
+			}
+		}
+	} else {
+		log.Println(ErrFileNotFound.Error())
+	}
+
+	return nil
+}
+
+func categoryLimitsToString(limits map[types.PaymentCategory]types.Money) string {
+	result := []string{}
+	for category, limit := range limits {
+		result = append(result, string(category)+"="+strconv.Itoa(int(limit)))
+	}
+
+	return strings.Join(result, ",")
+}
+
+func parseCategoryLimits(data string) (map[types.PaymentCategory]types.Money, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	limits := make(map[types.PaymentCategory]types.Money)
+	for _, pair := range strings.Split(data, ",") {
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, ErrInvalidLimit
+		}
+
+		limit, err := strconv.Atoi(pair[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		limits[types.PaymentCategory(pair[:i])] = types.Money(limit)
+	}
+
+	return limits, nil
+}
@@ -0,0 +1,1023 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+	"github.com/shuhrat-shokirov/wallet/pkg/vault"
+)
+
+var testVaultKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newCardService(t *testing.T) *Service {
+	v, err := vault.New(testVaultKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	svc := &Service{}
+	svc.SetVault(v)
+
+	return svc
+}
+
+func TestService_IssueCard_success(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := svc.FindCardByID(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.AccountID != account.ID {
+		t.Errorf("invalid account, got %v, want %v", got.AccountID, account.ID)
+	}
+
+	if got.Issuer != IssuerVisa {
+		t.Errorf("invalid issuer, got %v, want %v", got.Issuer, IssuerVisa)
+	}
+
+	if got.Number != "4111********1111" {
+		t.Errorf("invalid number, got %v", got.Number)
+	}
+}
+
+func TestService_IssueCard_invalidPAN(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.IssueCard(account.ID, "4111111111111112", "TJS")
+	if err != ErrInvalidPAN {
+		t.Error(err)
+	}
+
+	_, err = svc.IssueCard(account.ID, "4111", "TJS")
+	if err != ErrInvalidPANLength {
+		t.Error(err)
+	}
+
+	_, err = svc.IssueCard(account.ID, "9111111111111111", "TJS")
+	if err != ErrUnknownIssuer {
+		t.Error(err)
+	}
+
+	if len(svc.cards) != 0 {
+		t.Error("invalid card must not be stored")
+	}
+}
+
+func TestValidatePAN(t *testing.T) {
+	tests := []struct {
+		pan  string
+		want error
+	}{
+		{pan: "4111111111111111", want: nil},
+		{pan: "5555555555554444", want: nil},
+		{pan: "2221000000000009", want: nil},
+		{pan: "378282246310005", want: nil},
+		{pan: "6200000000000005", want: nil},
+		{pan: "4111-1111-1111-111a", want: ErrInvalidPAN},
+		{pan: "37828224631000", want: ErrInvalidPANLength},
+		{pan: "5555555555554445", want: ErrInvalidPAN},
+	}
+
+	for _, test := range tests {
+		err := ValidatePAN(test.pan)
+		if err != test.want {
+			t.Errorf("ValidatePAN(%v), got %v, want %v", test.pan, err, test.want)
+		}
+	}
+}
+
+func TestDetectIssuer(t *testing.T) {
+	tests := map[string]string{
+		"4111111111111111": IssuerVisa,
+		"5555555555554444": IssuerMastercard,
+		"2221000000000009": IssuerMastercard,
+		"378282246310005":  IssuerAmex,
+		"2200000000000004": IssuerMir,
+		"6200000000000005": IssuerUnionPay,
+		"9111111111111111": "",
+	}
+
+	for pan, want := range tests {
+		got := DetectIssuer(pan)
+		if got != want {
+			t.Errorf("DetectIssuer(%v), got %v, want %v", pan, got, want)
+		}
+	}
+}
+
+func TestMaskPAN(t *testing.T) {
+	tests := map[string]string{
+		"4111111111111111": "4111********1111",
+		"378282246310005":  "3782*******0005",
+		"4111********1111": "4111********1111",
+		"1234":             "****",
+	}
+
+	for pan, want := range tests {
+		got := MaskPAN(pan)
+		if got != want {
+			t.Errorf("MaskPAN(%v), got %v, want %v", pan, got, want)
+		}
+	}
+}
+
+func TestService_IssueCard_accountNotFound(t *testing.T) {
+	svc := newCardService(t)
+
+	_, err := svc.IssueCard(1, "4111111111111111", "TJS")
+	if err != ErrAccountNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_IssueCard_vaultNotConfigured(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != ErrVaultNotConfigured {
+		t.Error(err)
+	}
+}
+
+func TestService_DetokenizeCard(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111 1111 1111 1111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if strings.Contains(card.Token, "4111") {
+		t.Errorf("token must not contain pan, got %v", card.Token)
+	}
+
+	pan, err := svc.DetokenizeCard(card.ID, "support", "chargeback")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if pan != "4111111111111111" {
+		t.Errorf("invalid pan, got %v", pan)
+	}
+
+	if len(svc.vault.AuditLog()) != 1 {
+		t.Error("detokenize must be audited")
+	}
+}
+
+func TestService_FindCardByID_notFound(t *testing.T) {
+	svc := newCardService(t)
+
+	_, err := svc.FindCardByID(1)
+	if err != ErrCardNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_ListCardsByAccount(t *testing.T) {
+	svc := newCardService(t)
+
+	first, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	second, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.ListCardsByAccount(first.ID)
+	if err != ErrCardNotFound {
+		t.Error(err)
+	}
+
+	_, err = svc.IssueCard(first.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.IssueCard(first.ID, "5555555555554444", "USD")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.IssueCard(second.ID, "4012888888881881", "TJS")
+	if err != nil {
+		t.Error(err)
+	}
+
+	cards, err := svc.ListCardsByAccount(first.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(cards) != 2 {
+		t.Errorf("invalid result, got %v, want %v", len(cards), 2)
+	}
+}
+
+func TestService_ExportImport_cards(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 100
+	card.ExpiresAt = card.ExpiresAt.Truncate(time.Second)
+
+	err = svc.FreezeCard(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetCardDailyLimit(card.ID, 500)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetCardCategoryLimit(card.ID, "auto", 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := newCardService(t)
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindCardByID(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.Balance != 100 || got.AccountID != account.ID || got.Currency != "TJS" {
+		t.Errorf("invalid card, got %v", got)
+	}
+
+	if got.Status != types.CardStatusFrozen || !got.ExpiresAt.Equal(card.ExpiresAt) {
+		t.Errorf("invalid card lifecycle, got %v", got)
+	}
+
+	if got.DailyLimit != 500 || got.CategoryLimits["auto"] != 200 {
+		t.Errorf("invalid card limits, got %v", got)
+	}
+
+	dump, err := ioutil.ReadFile(dir + "/cards.dump")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if strings.Contains(string(dump), "4111111111111111") {
+		t.Error("exported cards must not contain raw pan")
+	}
+
+	pan, err := restored.DetokenizeCard(card.ID, "support", "restore check")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if pan != "4111111111111111" {
+		t.Errorf("invalid pan, got %v", pan)
+	}
+
+	next, err := restored.IssueCard(account.ID, "5555555555554444", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if next.ID == card.ID {
+		t.Error("card id must not be reused after import")
+	}
+}
+
+func TestService_DepositFromCard_success(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 1000
+
+	payment, err := svc.DepositFromCard(account.ID, card.ID, 400)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 400 || card.Balance != 600 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+
+	history, err := svc.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(history) != 1 || history[0].ID != payment.ID || history[0].Category != types.PaymentCategoryCardDeposit {
+		t.Errorf("invalid history, got %v", history)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 0 || card.Balance != 1000 {
+		t.Errorf("invalid balances after reject, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_DepositFromCard_fail(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 100
+
+	_, err = svc.DepositFromCard(account.ID, card.ID, 0)
+	if err != ErrAmountMustBePositive {
+		t.Error(err)
+	}
+
+	_, err = svc.DepositFromCard(account.ID, card.ID, 101)
+	if err != ErrNotEnoughCardBalance {
+		t.Error(err)
+	}
+
+	_, err = svc.DepositFromCard(other.ID, card.ID, 10)
+	if err != ErrCardNotLinked {
+		t.Error(err)
+	}
+
+	_, err = svc.DepositFromCard(account.ID, 2, 10)
+	if err != ErrCardNotFound {
+		t.Error(err)
+	}
+
+	if account.Balance != 0 || card.Balance != 100 {
+		t.Errorf("balances must not change, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_TransferToCard(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferToCard(account.ID, card.ID, 100)
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	account.Balance = 500
+
+	payment, err := svc.TransferToCard(account.ID, card.ID, 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Category != types.PaymentCategoryAccountToCard {
+		t.Errorf("invalid category, got %v", payment.Category)
+	}
+
+	if account.Balance != 300 || card.Balance != 200 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 500 || card.Balance != 0 {
+		t.Errorf("invalid balances after reject, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_TransferCardToCard(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	from.Balance = 500
+
+	to, err := svc.IssueCard(account.ID, "5555555555554444", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferCardToCard(from.ID, from.ID, 100)
+	if err != ErrSameCard {
+		t.Error(err)
+	}
+
+	_, err = svc.TransferCardToCard(from.ID, to.ID, 501)
+	if err != ErrNotEnoughCardBalance {
+		t.Error(err)
+	}
+
+	payment, err := svc.TransferCardToCard(from.ID, to.ID, 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 300 || to.Balance != 200 {
+		t.Errorf("invalid balances, from %v, to %v", from.Balance, to.Balance)
+	}
+
+	to.Balance = 100
+
+	err = svc.Reject(payment.ID)
+	if err != ErrNotEnoughCardBalance {
+		t.Error(err)
+	}
+
+	to.Balance = 200
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 500 || to.Balance != 0 || account.Balance != 0 {
+		t.Errorf("invalid balances after reject, from %v, to %v, account %v", from.Balance, to.Balance, account.Balance)
+	}
+}
+
+func TestService_CardLifecycle(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if card.Status != types.CardStatusActive {
+		t.Errorf("invalid status, got %v", card.Status)
+	}
+
+	err = svc.FreezeCard(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferToCard(account.ID, card.ID, 100)
+	if err != ErrCardFrozen {
+		t.Error(err)
+	}
+
+	err = svc.UnfreezeCard(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferToCard(account.ID, card.ID, 100)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = svc.BlockCard(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.UnfreezeCard(card.ID)
+	if err != ErrCardBlocked {
+		t.Error(err)
+	}
+
+	_, err = svc.DepositFromCard(account.ID, card.ID, 50)
+	if err != ErrCardBlocked {
+		t.Error(err)
+	}
+
+	if account.Balance != 900 || card.Balance != 100 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_CardExpiry(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := newCardService(t)
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 100
+
+	want := time.Date(2023, 11, 30, 23, 59, 59, 999999999, time.UTC)
+	if !card.ExpiresAt.Equal(want) {
+		t.Errorf("invalid expiry, got %v, want %v", card.ExpiresAt, want)
+	}
+
+	now = time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err = svc.DepositFromCard(account.ID, card.ID, 10)
+	if err != ErrCardExpired {
+		t.Error(err)
+	}
+
+	if card.Status != types.CardStatusExpired {
+		t.Errorf("invalid status, got %v", card.Status)
+	}
+
+	other, err := svc.IssueCard(account.ID, "5555555555554444", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.ExpireCard(other.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferCardToCard(other.ID, card.ID, 10)
+	if err != ErrCardExpired {
+		t.Error(err)
+	}
+}
+
+func TestService_PayByCard(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 1000
+
+	payment, err := svc.PayByCard(card.ID, 300, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.AccountID != account.ID || payment.CardID != card.ID || card.Balance != 700 {
+		t.Errorf("invalid payment, got %v", payment)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if card.Balance != 1000 || account.Balance != 0 {
+		t.Errorf("invalid balances after reject, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_CardLimits(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := newCardService(t)
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 10000
+
+	err = svc.SetCardDailyLimit(card.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetCardCategoryLimit(card.ID, "food", 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayByCard(card.ID, 200, "food")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.PayByCard(card.ID, 200, "food")
+	if err != ErrCardCategoryLimitExceeded {
+		t.Error(err)
+	}
+
+	_, err = svc.PayByCard(card.ID, 700, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.DepositFromCard(account.ID, card.ID, 200)
+	if err != ErrCardDailyLimitExceeded {
+		t.Error(err)
+	}
+
+	if card.Balance != 9100 || account.Balance != 0 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+
+	now = now.Add(12 * time.Hour)
+
+	_, err = svc.PayByCard(card.ID, 300, "food")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = svc.SetCardCategoryLimit(card.ID, "food", 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.PayByCard(card.ID, 500, "food")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = svc.SetCardDailyLimit(card.ID, -1)
+	if err != ErrAmountMustBePositive {
+		t.Error(err)
+	}
+}
+
+func TestService_CloseAccountWithPayout(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 500
+
+	err = svc.MoveToPocket(account.ID, "rent", 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.CloseAccountWithPayout(account.ID, card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Amount != 500 || card.Balance != 500 || account.Balance != 0 {
+		t.Errorf("invalid payout, account %v, card %v", account.Balance, card.Balance)
+	}
+
+	if account.Status != types.AccountStatusClosed {
+		t.Errorf("invalid status, got %v", account.Status)
+	}
+
+	_, err = svc.DepositFromCard(account.ID, card.ID, 100)
+	if err != ErrAccountClosed {
+		t.Error(err)
+	}
+}
+
+func TestService_Refund_card(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 1000
+
+	payment, err := svc.PayByCard(card.ID, 400, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 150)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if card.Balance != 750 || account.Balance != 0 {
+		t.Errorf("refund must go back to card, card %v, account %v", card.Balance, account.Balance)
+	}
+}
+
+func TestService_Import_vault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = os.Remove(dir + "/vault.dump")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := newCardService(t)
+	err = restored.Import(dir)
+	if err != nil {
+		t.Errorf("missing vault dump must be tolerated, got %v", err)
+		return
+	}
+
+	_, err = svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = ioutil.WriteFile(dir+"/vault.dump", []byte("broken\n"), 0666)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored = newCardService(t)
+	err = restored.Import(dir)
+	if err != vault.ErrInvalidDump {
+		t.Error(err)
+	}
+}
+
+func TestService_TransferToCard_notLinked(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 500
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(other.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferToCard(account.ID, card.ID, 100)
+	if err != ErrCardNotLinked {
+		t.Error(err)
+	}
+
+	if account.Balance != 500 || card.Balance != 0 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_CardPayments_frozenAccount(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 1000
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	otherCard, err := svc.IssueCard(other.ID, "5555555555554444", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayByCard(card.ID, 100, "shop")
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	_, err = svc.TransferCardToCard(card.ID, otherCard.ID, 100)
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	_, err = svc.TransferCardToCard(otherCard.ID, card.ID, 100)
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	if card.Balance != 1000 || otherCard.Balance != 0 {
+		t.Errorf("invalid balances, card %v, other %v", card.Balance, otherCard.Balance)
+	}
+}
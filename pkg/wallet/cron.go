@@ -0,0 +1,156 @@
+package wallet
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+const cronSearchLimit = 5 * 366 * 24 * time.Hour
+
+type cronSchedule struct {
+	minutes  []bool
+	hours    []bool
+	days     []bool
+	months   []bool
+	weekdays []bool
+	anyDay   bool
+	anyWeek  bool
+}
+
+func parseCron(spec string) (*cronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, ErrInvalidRecurrence
+	}
+
+	minutes, err := parseCronField(fields[0], 0, 59)
+	if err != nil {
+		return nil, err
+	}
+
+	hours, err := parseCronField(fields[1], 0, 23)
+	if err != nil {
+		return nil, err
+	}
+
+	days, err := parseCronField(fields[2], 1, 31)
+	if err != nil {
+		return nil, err
+	}
+
+	months, err := parseCronField(fields[3], 1, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	weekdays, err := parseCronField(fields[4], 0, 7)
+	if err != nil {
+		return nil, err
+	}
+	weekdays[0] = weekdays[0] || weekdays[7]
+
+	return &cronSchedule{
+		minutes:  minutes,
+		hours:    hours,
+		days:     days,
+		months:   months,
+		weekdays: weekdays,
+		anyDay:   fields[2] == "*",
+		anyWeek:  fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min int, max int) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, part := range strings.Split(field, ",") {
+		step, stepped := 1, false
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step <= 0 {
+				return nil, ErrInvalidRecurrence
+			}
+			part = part[:i]
+			stepped = true
+		}
+
+		from, to := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+
+			var err error
+			from, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, ErrInvalidRecurrence
+			}
+
+			if !stepped {
+				to = from
+			}
+			if len(bounds) == 2 {
+				to, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, ErrInvalidRecurrence
+				}
+			}
+		}
+
+		if from < min || to > max || from > to {
+			return nil, ErrInvalidRecurrence
+		}
+
+		for value := from; value <= to; value += step {
+			values[value] = true
+		}
+	}
+
+	return values, nil
+}
+
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	day := c.days[t.Day()]
+	weekday := c.weekdays[t.Weekday()]
+
+	switch {
+	case c.anyDay && c.anyWeek:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeek:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+func (c *cronSchedule) next(after time.Time) (time.Time, bool) {
+	t := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute()+1, 0, 0, after.Location())
+	limit := after.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !c.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+
+		return t, true
+	}
+
+	return time.Time{}, false
+}
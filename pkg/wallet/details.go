@@ -0,0 +1,108 @@
+package wallet
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+const metadataPrefix = "meta."
+
+func (s *Service) PayWithDetails(accountID int64, amount types.Money, category types.PaymentCategory, details types.PaymentDetails) (*types.Payment, error) {
+	payment, err := s.Pay(accountID, amount, category)
+	if err != nil {
+		return nil, err
+	}
+
+	payment.Details = cloneDetails(details)
+	return payment, nil
+}
+
+func (s *Service) SetFavoriteDetails(favoriteID string, details types.PaymentDetails) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	favorite.Details = cloneDetails(details)
+	return nil
+}
+
+func ByMerchant(merchant string) func(payment types.Payment) bool {
+	return func(payment types.Payment) bool {
+		return strings.EqualFold(payment.Details.Merchant, merchant)
+	}
+}
+
+func ByMetadata(key string, value string) func(payment types.Payment) bool {
+	return func(payment types.Payment) bool {
+		got, ok := payment.Details.Metadata[key]
+		return ok && got == value
+	}
+}
+
+func ByDescription(substr string) func(payment types.Payment) bool {
+	substr = strings.ToLower(substr)
+	return func(payment types.Payment) bool {
+		return strings.Contains(strings.ToLower(payment.Details.Description), substr)
+	}
+}
+
+func cloneDetails(details types.PaymentDetails) types.PaymentDetails {
+	if details.Metadata == nil {
+		return details
+	}
+
+	metadata := make(map[string]string, len(details.Metadata))
+	for key, value := range details.Metadata {
+		metadata[key] = value
+	}
+	details.Metadata = metadata
+
+	return details
+}
+
+func detailsToString(details types.PaymentDetails) string {
+	values := url.Values{}
+	if details.Description != "" {
+		values.Set("description", details.Description)
+	}
+	if details.Merchant != "" {
+		values.Set("merchant", details.Merchant)
+	}
+	for key, value := range details.Metadata {
+		values.Set(metadataPrefix+key, value)
+	}
+
+	return values.Encode()
+}
+
+func parseDetails(data string) (types.PaymentDetails, error) {
+	details := types.PaymentDetails{}
+	if data == "" {
+		return details, nil
+	}
+
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return details, err
+	}
+
+	for key := range values {
+		value := values.Get(key)
+		switch {
+		case key == "description":
+			details.Description = value
+		case key == "merchant":
+			details.Merchant = value
+		case strings.HasPrefix(key, metadataPrefix):
+			if details.Metadata == nil {
+				details.Metadata = make(map[string]string)
+			}
+			details.Metadata[strings.TrimPrefix(key, metadataPrefix)] = value
+		}
+	}
+
+	return details, nil
+}
@@ -0,0 +1,191 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_PayWithDetails_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	details := types.PaymentDetails{
+		Description: "taxi; home\nlate night",
+		Merchant:    "Yandex Go & Co",
+		Metadata: map[string]string{
+			"order": "42;43",
+			"note":  "a=b|c",
+		},
+	}
+
+	payment, err := svc.PayWithDetails(account.ID, 100, "auto", details)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "taxi")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	gotPayment, err := restored.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(gotPayment.Details, details) {
+		t.Errorf("invalid payment details, got %v, want %v", gotPayment.Details, details)
+	}
+
+	gotFavorite, err := restored.FindFavoriteByID(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(gotFavorite.Details, details) {
+		t.Errorf("invalid favorite details, got %v, want %v", gotFavorite.Details, details)
+	}
+
+	repeated, err := restored.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !reflect.DeepEqual(repeated.Details, details) {
+		t.Errorf("details must be passed from favorite, got %v", repeated.Details)
+	}
+}
+
+func TestService_FilterPaymentsByFn_details(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	taxi, err := svc.PayWithDetails(account.ID, 100, "auto", types.PaymentDetails{
+		Description: "Ride to the airport",
+		Merchant:    "Yandex Go",
+		Metadata:    map[string]string{"trip": "1"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayWithDetails(account.ID, 100, "auto", types.PaymentDetails{
+		Description: "Fuel",
+		Merchant:    "Lukoil",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	filters := []func(payment types.Payment) bool{
+		ByMerchant("yandex go"),
+		ByMetadata("trip", "1"),
+		ByDescription("AIRPORT"),
+	}
+
+	for i, filter := range filters {
+		payments, err := svc.FilterPaymentsByFn(filter, 1)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if len(payments) != 1 || payments[0].ID != taxi.ID {
+			t.Errorf("filter %v: invalid payments, got %v", i, payments)
+		}
+	}
+
+	_, err = svc.FilterPaymentsByFn(ByMetadata("trip", "2"), 1)
+	if err == nil {
+		t.Error("no payments must match")
+	}
+}
+
+func TestService_SetFavoriteDetails(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	favorite, err := svc.CreateFavorite(account.ID, "internet", 100, "internet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	details := types.PaymentDetails{
+		Description: "Home internet",
+		Merchant:    "Babilon-T",
+		Metadata:    map[string]string{"contract": "12345"},
+	}
+
+	err = svc.SetFavoriteDetails(favorite.ID, details)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	details.Metadata["contract"] = "changed"
+
+	payment, err := svc.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Details.Merchant != "Babilon-T" || payment.Details.Description != "Home internet" || payment.Details.Metadata["contract"] != "12345" {
+		t.Errorf("invalid payment details, got %v", payment.Details)
+	}
+
+	err = svc.SetFavoriteDetails("unknown", details)
+	if err != ErrFavoriteNotFound {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,291 @@
+package wallet
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func (s *Service) CreateFavorite(accountID int64, name string, amount types.Money, category types.PaymentCategory) (*types.Favorite, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err = s.checkFavoriteName(accountID, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite := &types.Favorite{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Name:      name,
+		Amount:    amount,
+		Category:  category,
+	}
+
+	s.addFavorite(favorite)
+
+	return favorite, nil
+}
+
+func (s *Service) ListFavorites(accountID int64) ([]types.Favorite, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	favorites := []types.Favorite{}
+	for _, favorite := range s.favoritesByAccount[accountID] {
+		favorites = append(favorites, *favorite)
+	}
+
+	sort.SliceStable(favorites, func(i, j int) bool {
+		return strings.ToLower(favorites[i].Name) < strings.ToLower(favorites[j].Name)
+	})
+
+	return favorites, nil
+}
+
+func (s *Service) RenameFavorite(favoriteID string, name string) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	name, err = s.checkFavoriteName(favorite.AccountID, name, favorite)
+	if err != nil {
+		return err
+	}
+
+	favorite.Name = name
+	return nil
+}
+
+func (s *Service) UpdateFavorite(favoriteID string, amount types.Money, category types.PaymentCategory) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	err = checkFavoriteAmount(amount, favorite.Template)
+	if err != nil {
+		return err
+	}
+
+	favorite.Amount = amount
+	favorite.Category = category
+	return nil
+}
+
+func (s *Service) DeleteFavorite(favoriteID string) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	s.favorites = removeFavorite(s.favorites, favorite)
+	s.favoritesByAccount[favorite.AccountID] = removeFavorite(s.favoritesByAccount[favorite.AccountID], favorite)
+	delete(s.favoritesByID, favorite.ID)
+	delete(s.recurringRuns, favorite.ID)
+
+	return nil
+}
+
+func (s *Service) CreateFavoriteTemplate(accountID int64, name string, category types.PaymentCategory, template types.FavoriteTemplate) (*types.Favorite, error) {
+	err := validateTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	name, err = s.checkFavoriteName(accountID, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	favorite := &types.Favorite{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Name:      name,
+		Category:  category,
+		Details:   types.PaymentDetails{Merchant: template.Payee},
+		Template:  &template,
+	}
+
+	s.addFavorite(favorite)
+
+	return favorite, nil
+}
+
+func (s *Service) SetFavoriteTemplate(favoriteID string, template types.FavoriteTemplate) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	err = validateTemplate(template)
+	if err != nil {
+		return err
+	}
+
+	if favorite.Amount != 0 {
+		err = checkFavoriteAmount(favorite.Amount, &template)
+		if err != nil {
+			return err
+		}
+	}
+
+	favorite.Template = &template
+	if template.Payee != "" {
+		favorite.Details.Merchant = template.Payee
+	}
+
+	return nil
+}
+
+func (s *Service) PayFromFavoriteWith(favoriteID string, params types.FavoriteParams) (*types.Payment, error) {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := params.Amount
+	if amount == 0 {
+		amount = favorite.Amount
+	}
+
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	err = checkFavoriteAmount(amount, favorite.Template)
+	if err != nil {
+		return nil, err
+	}
+
+	details := cloneDetails(favorite.Details)
+	if params.Description != "" {
+		details.Description = params.Description
+	}
+
+	reference := params.Reference
+	if reference == "" && favorite.Template != nil {
+		reference = favorite.Template.Reference
+	}
+
+	if reference != "" {
+		if details.Metadata == nil {
+			details.Metadata = make(map[string]string)
+		}
+		details.Metadata["reference"] = reference
+	}
+
+	return s.PayWithDetails(favorite.AccountID, amount, favorite.Category, details)
+}
+
+func validateTemplate(template types.FavoriteTemplate) error {
+	if template.MinAmount < 0 || template.MaxAmount < 0 {
+		return ErrInvalidAmountBounds
+	}
+
+	if template.MaxAmount != 0 && template.MaxAmount < template.MinAmount {
+		return ErrInvalidAmountBounds
+	}
+
+	return nil
+}
+
+func checkFavoriteAmount(amount types.Money, template *types.FavoriteTemplate) error {
+	if template == nil {
+		if amount <= 0 {
+			return ErrAmountMustBePositive
+		}
+		return nil
+	}
+
+	if amount < 0 {
+		return ErrAmountMustBePositive
+	}
+
+	if amount == 0 {
+		return nil
+	}
+
+	if amount < template.MinAmount || (template.MaxAmount != 0 && amount > template.MaxAmount) {
+		return ErrAmountOutOfBounds
+	}
+
+	return nil
+}
+
+func templateToString(template *types.FavoriteTemplate) string {
+	if template == nil {
+		return ""
+	}
+
+	values := url.Values{}
+	values.Set("payee", template.Payee)
+	values.Set("reference", template.Reference)
+	values.Set("min", strconv.Itoa(int(template.MinAmount)))
+	values.Set("max", strconv.Itoa(int(template.MaxAmount)))
+
+	return values.Encode()
+}
+
+func parseTemplate(data string) (*types.FavoriteTemplate, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	min, err := strconv.Atoi(values.Get("min"))
+	if err != nil {
+		return nil, err
+	}
+
+	max, err := strconv.Atoi(values.Get("max"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.FavoriteTemplate{
+		Payee:     values.Get("payee"),
+		Reference: values.Get("reference"),
+		MinAmount: types.Money(min),
+		MaxAmount: types.Money(max),
+	}, nil
+}
+
+func (s *Service) checkFavoriteName(accountID int64, name string, self *types.Favorite) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.ContainsAny(name, ";\r\n") {
+		return "", ErrInvalidFavoriteName
+	}
+
+	for _, favorite := range s.favoritesByAccount[accountID] {
+		if favorite != self && strings.EqualFold(favorite.Name, name) {
+			return "", ErrFavoriteNameTaken
+		}
+	}
+
+	return name, nil
+}
@@ -0,0 +1,388 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_CreateFavorite(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	favorite, err := svc.CreateFavorite(account.ID, " Internet ", 100, "internet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if favorite.Name != "Internet" {
+		t.Errorf("name must be trimmed, got %q", favorite.Name)
+	}
+
+	_, err = svc.CreateFavorite(account.ID, "internet", 200, "internet")
+	if err != ErrFavoriteNameTaken {
+		t.Error(err)
+	}
+
+	_, err = svc.CreateFavorite(account.ID, "  ", 200, "internet")
+	if err != ErrInvalidFavoriteName {
+		t.Error(err)
+	}
+
+	for _, name := range []string{"x;y", "x\ny"} {
+		_, err = svc.CreateFavorite(account.ID, name, 200, "internet")
+		if err != ErrInvalidFavoriteName {
+			t.Errorf("%q: got %v", name, err)
+		}
+
+		err = svc.RenameFavorite(favorite.ID, name)
+		if err != ErrInvalidFavoriteName {
+			t.Errorf("%q: got %v", name, err)
+		}
+	}
+
+	_, err = svc.CreateFavorite(account.ID+1, "phone", 200, "phone")
+	if err != ErrAccountNotFound {
+		t.Error(err)
+	}
+
+	payment, err := svc.PayFromFavorite(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.FavoritePayment(payment.ID, "INTERNET")
+	if err != ErrFavoriteNameTaken {
+		t.Error(err)
+	}
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.CreateFavorite(other.ID, "Internet", 100, "internet")
+	if err != nil {
+		t.Errorf("names must be unique per account only, got %v", err)
+	}
+}
+
+func TestService_ListFavorites(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, name := range []string{"phone", "Internet", "gym"} {
+		_, err = svc.CreateFavorite(account.ID, name, 100, types.PaymentCategory(name))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	favorites, err := svc.ListFavorites(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []string{"gym", "Internet", "phone"}
+	if len(favorites) != len(want) {
+		t.Errorf("invalid favorites, got %v", favorites)
+		return
+	}
+
+	for i, favorite := range favorites {
+		if favorite.Name != want[i] {
+			t.Errorf("invalid favorite %v, got %v, want %v", i, favorite.Name, want[i])
+		}
+	}
+}
+
+func TestService_UpdateFavorite(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	internet, err := svc.CreateFavorite(account.ID, "internet", 100, "internet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.CreateFavorite(account.ID, "phone", 50, "phone")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.RenameFavorite(internet.ID, "phone")
+	if err != ErrFavoriteNameTaken {
+		t.Error(err)
+	}
+
+	err = svc.RenameFavorite(internet.ID, "Internet")
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = svc.RenameFavorite(internet.ID, "home internet")
+	if err != nil || internet.Name != "home internet" {
+		t.Errorf("favorite must be renamed, got %v, %v", internet.Name, err)
+	}
+
+	err = svc.UpdateFavorite(internet.ID, 0, "internet")
+	if err != ErrAmountMustBePositive {
+		t.Error(err)
+	}
+
+	err = svc.UpdateFavorite(internet.ID, 150, "home")
+	if err != nil || internet.Amount != 150 || internet.Category != "home" {
+		t.Errorf("favorite must be updated, got %v, %v", internet, err)
+	}
+
+	err = svc.UpdateFavorite("unknown", 150, "home")
+	if err != ErrFavoriteNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_DeleteFavorite(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	favorite, err := svc.CreateFavorite(account.ID, "internet", 100, "internet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.FindFavoriteByID(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Error(err)
+	}
+
+	favorites, err := svc.ListFavorites(account.ID)
+	if err != nil || len(favorites) != 0 {
+		t.Errorf("favorite must be removed from account, got %v, %v", favorites, err)
+	}
+
+	err = svc.DeleteFavorite(favorite.ID)
+	if err != ErrFavoriteNotFound {
+		t.Error(err)
+	}
+
+	_, err = svc.CreateFavorite(account.ID, "internet", 100, "internet")
+	if err != nil {
+		t.Errorf("name must be free after delete, got %v", err)
+	}
+}
+
+func TestService_PayFromFavoriteWith(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	_, err = svc.CreateFavoriteTemplate(account.ID, "bad", "utility", types.FavoriteTemplate{MinAmount: 200, MaxAmount: 100})
+	if err != ErrInvalidAmountBounds {
+		t.Error(err)
+	}
+
+	favorite, err := svc.CreateFavoriteTemplate(account.ID, "electricity", "utility", types.FavoriteTemplate{
+		Payee:     "Barqi Tojik",
+		Reference: "contract-1",
+		MinAmount: 10,
+		MaxAmount: 500,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != ErrAmountMustBePositive {
+		t.Error(err)
+	}
+
+	_, err = svc.PayFromFavoriteWith(favorite.ID, types.FavoriteParams{Amount: 5})
+	if err != ErrAmountOutOfBounds {
+		t.Error(err)
+	}
+
+	_, err = svc.PayFromFavoriteWith(favorite.ID, types.FavoriteParams{Amount: 600})
+	if err != ErrAmountOutOfBounds {
+		t.Error(err)
+	}
+
+	payment, err := svc.PayFromFavoriteWith(favorite.ID, types.FavoriteParams{
+		Amount:      120,
+		Reference:   "invoice-2020-11",
+		Description: "November bill",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Amount != 120 || payment.Category != "utility" {
+		t.Errorf("invalid payment, got %v", payment)
+	}
+
+	if payment.Details.Merchant != "Barqi Tojik" || payment.Details.Description != "November bill" || payment.Details.Metadata["reference"] != "invoice-2020-11" {
+		t.Errorf("invalid payment details, got %v", payment.Details)
+	}
+
+	payment, err = svc.PayFromFavoriteWith(favorite.ID, types.FavoriteParams{Amount: 80})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Details.Metadata["reference"] != "contract-1" {
+		t.Errorf("template reference must be used by default, got %v", payment.Details)
+	}
+
+	if favorite.Details.Metadata != nil {
+		t.Errorf("favorite details must not change, got %v", favorite.Details)
+	}
+
+	if account.Balance != 800 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+}
+
+func TestService_SetFavoriteTemplate(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	favorite, err := svc.CreateFavorite(account.ID, "water", 100, "utility")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetFavoriteTemplate(favorite.ID, types.FavoriteTemplate{MinAmount: 200})
+	if err != ErrAmountOutOfBounds {
+		t.Error(err)
+	}
+
+	err = svc.SetFavoriteTemplate(favorite.ID, types.FavoriteTemplate{Payee: "Vodokanal", MaxAmount: 300})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.UpdateFavorite(favorite.ID, 0, "utility")
+	if err != nil {
+		t.Errorf("template amount may be left to pay time, got %v", err)
+	}
+
+	err = svc.UpdateFavorite(favorite.ID, 400, "utility")
+	if err != ErrAmountOutOfBounds {
+		t.Error(err)
+	}
+
+	payment, err := svc.PayFromFavoriteWith(favorite.ID, types.FavoriteParams{Amount: 250})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Details.Merchant != "Vodokanal" {
+		t.Errorf("invalid payee, got %v", payment.Details.Merchant)
+	}
+}
+
+func TestService_FavoriteTemplate_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	template := types.FavoriteTemplate{
+		Payee:     "Gas; Co",
+		Reference: "a=b&c",
+		MinAmount: 10,
+		MaxAmount: 500,
+	}
+
+	favorite, err := svc.CreateFavoriteTemplate(account.ID, "gas", "utility", template)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindFavoriteByID(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.Template == nil || *got.Template != template {
+		t.Errorf("invalid template, got %v, want %v", got.Template, template)
+	}
+}
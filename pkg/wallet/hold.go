@@ -0,0 +1,100 @@
+package wallet
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func (s *Service) Authorize(accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkPaymentLimits(account, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if availableBalance(account) < amount {
+		return nil, ErrNotEnoughBalance
+	}
+
+	account.Held += amount
+
+	payment := &types.Payment{
+		ID:         uuid.New().String(),
+		AccountID:  account.ID,
+		Amount:     amount,
+		Category:   category,
+		Status:     types.PaymentStatusAuthorized,
+		Authorized: amount,
+	}
+
+	s.addPayment(payment)
+
+	return payment, nil
+}
+
+func (s *Service) Capture(paymentID string, amount types.Money) (*types.Payment, error) {
+	payment, account, err := s.findPaymentAndAccountByPaymentID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if payment.Status != types.PaymentStatusAuthorized {
+		return nil, ErrPaymentNotAuthorized
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	if amount < 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	if amount == 0 {
+		amount = payment.Amount
+	}
+
+	if amount > payment.Amount {
+		return nil, ErrCaptureExceedsHold
+	}
+
+	account.Held -= payment.Amount
+	account.Balance -= amount
+	s.addTurnover(account.ID, amount)
+
+	payment.Amount = amount
+	s.setStatus(payment, types.PaymentStatusOk)
+
+	return payment, nil
+}
+
+func (s *Service) Void(paymentID string) error {
+	payment, account, err := s.findPaymentAndAccountByPaymentID(paymentID)
+	if err != nil {
+		return err
+	}
+
+	if payment.Status != types.PaymentStatusAuthorized {
+		return ErrPaymentNotAuthorized
+	}
+
+	account.Held -= payment.Amount
+	s.setStatus(payment, types.PaymentStatusVoided)
+
+	return nil
+}
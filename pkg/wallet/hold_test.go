@@ -0,0 +1,212 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_AuthorizeCapture(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Authorize(account.ID, 700, "hotel")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Status != types.PaymentStatusAuthorized || account.Balance != 1000 || account.Held != 700 {
+		t.Errorf("invalid hold, balance %v, held %v", account.Balance, account.Held)
+	}
+
+	_, err = svc.Pay(account.ID, 400, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	_, err = svc.Capture(payment.ID, 800)
+	if err != ErrCaptureExceedsHold {
+		t.Error(err)
+	}
+
+	_, err = svc.Capture(payment.ID, 650)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Status != types.PaymentStatusOk || payment.Amount != 650 || account.Balance != 350 || account.Held != 0 {
+		t.Errorf("invalid capture, balance %v, held %v, payment %v", account.Balance, account.Held, payment)
+	}
+
+	_, err = svc.Capture(payment.ID, 0)
+	if err != ErrPaymentNotAuthorized {
+		t.Error(err)
+	}
+
+	err = svc.Void(payment.ID)
+	if err != ErrPaymentNotAuthorized {
+		t.Error(err)
+	}
+}
+
+func TestService_AuthorizeVoid(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Authorize(account.ID, 1001, "hotel")
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	payment, err := svc.Authorize(account.ID, 1000, "hotel")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.CloseAccount(account.ID)
+	if err != ErrAccountHasHolds {
+		t.Error(err)
+	}
+
+	err = svc.Void(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Status != types.PaymentStatusVoided || account.Balance != 1000 || account.Held != 0 {
+		t.Errorf("invalid void, balance %v, held %v", account.Balance, account.Held)
+	}
+
+	full, err := svc.Authorize(account.ID, 300, "hotel")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Capture(full.ID, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if full.Amount != 300 || account.Balance != 700 {
+		t.Errorf("invalid capture, balance %v, payment %v", account.Balance, full)
+	}
+}
+
+func TestService_Capture_frozen(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	payment, err := svc.Authorize(account.ID, 300, "hotel")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Capture(payment.ID, 0)
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	if account.Balance != 1000 || account.Held != 300 || payment.Status != types.PaymentStatusAuthorized {
+		t.Errorf("hold must stay untouched, got %v, %v, %v", account.Balance, account.Held, payment.Status)
+	}
+}
+
+func TestService_Capture_keepsAuthorized(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	payment, err := svc.Authorize(account.ID, 300, "hotel")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Capture(payment.ID, 120)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Amount != 120 || payment.Authorized != 300 {
+		t.Errorf("invalid amounts, captured %v, authorized %v", payment.Amount, payment.Authorized)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.Amount != 120 || got.Authorized != 300 {
+		t.Errorf("amounts must survive import, captured %v, authorized %v", got.Amount, got.Authorized)
+	}
+}
@@ -0,0 +1,121 @@
+package wallet
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+const DefaultIdempotencyRetention = 24 * time.Hour
+
+type idempotencyRecord struct {
+	key       string
+	operation string
+	params    string
+	paymentID string
+	createdAt time.Time
+}
+
+func (s *Service) SetIdempotencyRetention(retention time.Duration) {
+	s.idempotencyRetention = retention
+}
+
+func (s *Service) PayWithKey(key string, accountID int64, amount types.Money, category types.PaymentCategory) (*types.Payment, error) {
+	params := strconv.FormatInt(accountID, 10) + ";" + strconv.Itoa(int(amount)) + ";" + string(category)
+
+	return s.idempotent(key, "pay", params, func() (*types.Payment, error) {
+		return s.Pay(accountID, amount, category)
+	})
+}
+
+func (s *Service) DepositWithKey(key string, accountID int64, amount types.Money) error {
+	params := strconv.FormatInt(accountID, 10) + ";" + strconv.Itoa(int(amount))
+
+	_, err := s.idempotent(key, "deposit", params, func() (*types.Payment, error) {
+		return nil, s.Deposit(accountID, amount)
+	})
+
+	return err
+}
+
+func (s *Service) TransferWithKey(key string, fromID int64, toID int64, amount types.Money) (*types.Payment, error) {
+	params := strconv.FormatInt(fromID, 10) + ";" + strconv.FormatInt(toID, 10) + ";" + strconv.Itoa(int(amount))
+
+	return s.idempotent(key, "transfer", params, func() (*types.Payment, error) {
+		return s.Transfer(fromID, toID, amount)
+	})
+}
+
+func (s *Service) idempotent(key string, operation string, params string, action func() (*types.Payment, error)) (*types.Payment, error) {
+	if key == "" {
+		return action()
+	}
+
+	s.purgeIdempotencyKeys()
+
+	record, ok := s.idempotencyKeys[key]
+	if ok && s.idempotencyExpired(record) {
+		delete(s.idempotencyKeys, key)
+		ok = false
+	}
+
+	if ok {
+		if record.operation != operation || record.params != params {
+			return nil, ErrIdempotencyConflict
+		}
+
+		if record.paymentID == "" {
+			return nil, nil
+		}
+
+		return s.FindPaymentByID(record.paymentID)
+	}
+
+	payment, err := action()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.idempotencyKeys == nil {
+		s.idempotencyKeys = make(map[string]*idempotencyRecord)
+	}
+
+	record = &idempotencyRecord{
+		key:       key,
+		operation: operation,
+		params:    params,
+		createdAt: s.now(),
+	}
+	if payment != nil {
+		record.paymentID = payment.ID
+	}
+	s.idempotencyKeys[key] = record
+	s.idempotencyQueue = append(s.idempotencyQueue, record)
+
+	return payment, nil
+}
+
+func (s *Service) purgeIdempotencyKeys() {
+	for len(s.idempotencyQueue) > 0 {
+		record := s.idempotencyQueue[0]
+		if !s.idempotencyExpired(record) {
+			return
+		}
+
+		if s.idempotencyKeys[record.key] == record {
+			delete(s.idempotencyKeys, record.key)
+		}
+		s.idempotencyQueue[0] = nil
+		s.idempotencyQueue = s.idempotencyQueue[1:]
+	}
+}
+
+func (s *Service) idempotencyExpired(record *idempotencyRecord) bool {
+	retention := s.idempotencyRetention
+	if retention <= 0 {
+		retention = DefaultIdempotencyRetention
+	}
+
+	return s.now().Sub(record.createdAt) >= retention
+}
@@ -0,0 +1,206 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+)
+
+func TestService_PayWithKey(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+	svc.SetIdempotencyRetention(time.Hour)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	first, err := svc.PayWithKey("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	retry, err := svc.PayWithKey("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if retry.ID != first.ID || account.Balance != 900 {
+		t.Errorf("retry must return the original payment, got %v, balance %v", retry.ID, account.Balance)
+	}
+
+	_, err = svc.PayWithKey("key-1", account.ID, 200, "auto")
+	if err != ErrIdempotencyConflict {
+		t.Error(err)
+	}
+
+	_, err = svc.TransferWithKey("key-1", account.ID, 2, 100)
+	if err != ErrIdempotencyConflict {
+		t.Error(err)
+	}
+
+	now = now.Add(time.Hour)
+
+	expired, err := svc.PayWithKey("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if expired.ID == first.ID || account.Balance != 800 {
+		t.Errorf("expired key must create a new payment, balance %v", account.Balance)
+	}
+
+	_, err = svc.PayWithKey("", account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.PayWithKey("", account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if account.Balance != 600 {
+		t.Errorf("empty key must not deduplicate, balance %v", account.Balance)
+	}
+}
+
+func TestService_PayWithKey_failedRetry(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayWithKey("key-1", account.ID, 100, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	err = svc.DepositWithKey("key-2", account.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.DepositWithKey("key-2", account.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 100 {
+		t.Errorf("deposit retry must not be applied twice, balance %v", account.Balance)
+	}
+
+	_, err = svc.PayWithKey("key-1", account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_TransferWithKey(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	first, err := svc.TransferWithKey("key-1", from.ID, to.ID, 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	retry, err := svc.TransferWithKey("key-1", from.ID, to.ID, 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if retry.ID != first.ID || from.Balance != 700 || to.Balance != 300 {
+		t.Errorf("invalid retry, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_idempotencyExpiry(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+	svc.SetIdempotencyRetention(time.Hour)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, key := range []string{"key-1", "key-2", "key-3"} {
+		err = svc.DepositWithKey(key, account.ID, 100)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		now = now.Add(20 * time.Minute)
+	}
+
+	err = svc.DepositWithKey("key-1", account.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 400 {
+		t.Errorf("expired key must execute again, got %v", account.Balance)
+	}
+
+	now = now.Add(30 * time.Minute)
+	err = svc.DepositWithKey("key-4", account.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(svc.idempotencyKeys) != 3 || len(svc.idempotencyQueue) != 3 {
+		t.Errorf("expired keys must be purged, got %v keys, %v queued", len(svc.idempotencyKeys), len(svc.idempotencyQueue))
+	}
+
+	err = svc.DepositWithKey("key-1", account.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 500 {
+		t.Errorf("renewed key must stay cached, got %v", account.Balance)
+	}
+}
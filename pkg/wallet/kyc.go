@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"strings"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+type TierLimits struct {
+	MaxBalance      types.Money
+	MaxPayment      types.Money
+	MonthlyTurnover types.Money
+}
+
+var DefaultTierPolicies = map[types.KYCLevel]TierLimits{
+	types.KYCLevelAnonymous: {
+		MaxBalance:      10_000_00,
+		MaxPayment:      5_000_00,
+		MonthlyTurnover: 30_000_00,
+	},
+	types.KYCLevelBasic: {
+		MaxBalance:      100_000_00,
+		MaxPayment:      50_000_00,
+		MonthlyTurnover: 300_000_00,
+	},
+	types.KYCLevelFull: {
+		MaxBalance:      1_000_000_00,
+		MaxPayment:      500_000_00,
+		MonthlyTurnover: 5_000_000_00,
+	},
+}
+
+var kycRanks = map[types.KYCLevel]int{
+	types.KYCLevelAnonymous: 0,
+	types.KYCLevelBasic:     1,
+	types.KYCLevelFull:      2,
+}
+
+type accountTurnover struct {
+	month time.Time
+	total types.Money
+}
+
+func (s *Service) SetTierPolicy(level types.KYCLevel, limits TierLimits) error {
+	_, ok := kycRanks[level]
+	if !ok {
+		return ErrInvalidKYCLevel
+	}
+
+	if s.tierPolicies == nil {
+		s.tierPolicies = make(map[types.KYCLevel]TierLimits)
+	}
+	s.tierPolicies[level] = limits
+	s.retryClaims()
+
+	return nil
+}
+
+func (s *Service) UpgradeKYC(accountID int64, level types.KYCLevel, verifiedBy string) error {
+	newRank, ok := kycRanks[level]
+	if !ok {
+		return ErrInvalidKYCLevel
+	}
+
+	if verifiedBy == "" || strings.ContainsAny(verifiedBy, ";\n") {
+		return ErrInvalidVerifier
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	if newRank <= kycRanks[kycLevel(account)] {
+		return ErrKYCDowngrade
+	}
+
+	account.KYCLevel = level
+	account.VerifiedBy = verifiedBy
+	s.payoutClaims(account)
+
+	return nil
+}
+
+func (s *Service) tierPolicy(account *types.Account) TierLimits {
+	level := kycLevel(account)
+
+	limits, ok := s.tierPolicies[level]
+	if ok {
+		return limits
+	}
+
+	return DefaultTierPolicies[level]
+}
+
+func (s *Service) checkDepositLimits(account *types.Account, amount types.Money) error {
+	limits := s.tierPolicy(account)
+
+	if limits.MaxBalance > 0 && totalBalance(account)+amount > limits.MaxBalance {
+		return ErrKYCBalanceLimitExceeded
+	}
+
+	return s.checkTurnover(account, amount, limits)
+}
+
+func (s *Service) checkPaymentLimits(account *types.Account, amount types.Money) error {
+	limits := s.tierPolicy(account)
+
+	if limits.MaxPayment > 0 && amount > limits.MaxPayment {
+		return ErrKYCPaymentLimitExceeded
+	}
+
+	return s.checkTurnover(account, amount, limits)
+}
+
+func (s *Service) checkTurnover(account *types.Account, amount types.Money, limits TierLimits) error {
+	if limits.MonthlyTurnover > 0 && s.turnoverFor(account.ID).total+amount > limits.MonthlyTurnover {
+		return ErrKYCTurnoverLimitExceeded
+	}
+
+	return nil
+}
+
+func (s *Service) addTurnover(accountID int64, amount types.Money) {
+	s.turnoverFor(accountID).total += amount
+}
+
+func (s *Service) turnoverFor(accountID int64) *accountTurnover {
+	now := s.now()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	if s.turnovers == nil {
+		s.turnovers = make(map[int64]*accountTurnover)
+	}
+
+	turnover, ok := s.turnovers[accountID]
+	if !ok || !turnover.month.Equal(month) {
+		turnover = &accountTurnover{month: month}
+		s.turnovers[accountID] = turnover
+	}
+
+	return turnover
+}
+
+func kycLevel(account *types.Account) types.KYCLevel {
+	if account.KYCLevel == "" {
+		return types.KYCLevelAnonymous
+	}
+
+	return account.KYCLevel
+}
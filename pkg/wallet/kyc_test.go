@@ -0,0 +1,354 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_KYC_depositLimits(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.KYCLevel != types.KYCLevelAnonymous {
+		t.Errorf("invalid kyc level, got %v", account.KYCLevel)
+	}
+
+	err = svc.Deposit(account.ID, 10_000_00)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1)
+	if err != ErrKYCBalanceLimitExceeded {
+		t.Error(err)
+	}
+
+	err = svc.UpgradeKYC(account.ID, types.KYCLevelBasic, "operator-1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.VerifiedBy != "operator-1" {
+		t.Errorf("invalid verifier, got %v", account.VerifiedBy)
+	}
+
+	err = svc.Deposit(account.ID, 1)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestService_KYC_paymentLimits(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{
+		MaxBalance:      1000,
+		MaxPayment:      300,
+		MonthlyTurnover: 1500,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Pay(account.ID, 301, "auto")
+	if err != ErrKYCPaymentLimitExceeded {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, 300, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, 200, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, 1, "auto")
+	if err != ErrKYCTurnoverLimitExceeded {
+		t.Error(err)
+	}
+
+	now = time.Date(2020, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	_, err = svc.Pay(account.ID, 1, "auto")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if account.Balance != 499 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+}
+
+func TestService_UpgradeKYC_fail(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.UpgradeKYC(account.ID, "GOLD", "operator-1")
+	if err != ErrInvalidKYCLevel {
+		t.Error(err)
+	}
+
+	err = svc.UpgradeKYC(account.ID, types.KYCLevelFull, "")
+	if err != ErrInvalidVerifier {
+		t.Error(err)
+	}
+
+	err = svc.UpgradeKYC(account.ID, types.KYCLevelFull, "operator-1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.UpgradeKYC(account.ID, types.KYCLevelBasic, "operator-2")
+	if err != ErrKYCDowngrade {
+		t.Error(err)
+	}
+
+	err = svc.UpgradeKYC(2, types.KYCLevelFull, "operator-1")
+	if err != ErrAccountNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_KYC_transferLimits(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.UpgradeKYC(from.ID, types.KYCLevelFull, "operator-1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	from.Balance = 400_000_00
+
+	_, err = svc.Transfer(from.ID, to.ID, 400_000_00)
+	if err != ErrKYCBalanceLimitExceeded {
+		t.Errorf("receiver tier must be enforced, got %v", err)
+	}
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{
+		MaxBalance:      1000,
+		MaxPayment:      300,
+		MonthlyTurnover: 500,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Transfer(from.ID, to.ID, 400)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Transfer(to.ID, from.ID, 301)
+	if err != ErrKYCPaymentLimitExceeded {
+		t.Errorf("sender tier must be enforced, got %v", err)
+	}
+
+	_, err = svc.PayByPhone(to.ID, "+992000000002", 101)
+	if err != ErrKYCTurnoverLimitExceeded {
+		t.Errorf("turnover must include transfers, got %v", err)
+	}
+
+	_, err = svc.PayByPhone(to.ID, "+992000000002", 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 400_000_00-400 || to.Balance != 300 {
+		t.Errorf("invalid balances, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_KYC_claimLimits(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	from.Balance = 1000
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{MaxBalance: 300})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayByPhone(from.ID, "+992000000001", 500)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if to.Balance != 0 {
+		t.Errorf("claim over the tier limit must not be paid, got %v", to.Balance)
+	}
+
+	claims, err := svc.FindClaimsByPhone("+992000000001")
+	if err != nil || len(claims) != 1 {
+		t.Errorf("claim must stay pending, got %v, %v", claims, err)
+	}
+
+	err = svc.UpgradeKYC(to.ID, types.KYCLevelBasic, "operator-1")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if to.Balance != 500 {
+		t.Errorf("claim must be paid after upgrade, got %v", to.Balance)
+	}
+
+	_, err = svc.FindClaimsByPhone("+992000000001")
+	if err != ErrClaimNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_KYC_claimLimits_policy(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	from.Balance = 1000
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{MaxBalance: 300})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.PayByPhone(from.ID, "+992000000001", 500)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{MaxBalance: 1000})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if to.Balance != 500 {
+		t.Errorf("claim must be paid after policy change, got %v", to.Balance)
+	}
+}
+
+func TestService_KYC_transferToCardLimits(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 10_000_00)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.TransferToCard(account.ID, card.ID, 10_000_00)
+	if err != ErrKYCPaymentLimitExceeded {
+		t.Error(err)
+	}
+
+	_, err = svc.TransferToCard(account.ID, card.ID, 5_000_00)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if svc.turnoverFor(account.ID).total != 15_000_00 {
+		t.Errorf("invalid turnover, got %v", svc.turnoverFor(account.ID).total)
+	}
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{MaxPayment: 100})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.CloseAccountWithPayout(account.ID, card.ID)
+	if err != nil {
+		t.Errorf("closing payout must skip tier limits, got %v", err)
+	}
+
+	if account.Balance != 0 || card.Balance != 10_000_00 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+}
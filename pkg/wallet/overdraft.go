@@ -0,0 +1,103 @@
+package wallet
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+const basisPoints = 10_000
+
+func (s *Service) SetCreditLine(accountID int64, limit types.Money, rate int64) error {
+	if limit < 0 || rate < 0 {
+		return ErrInvalidLimit
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	account.CreditLimit = limit
+	account.OverdraftRate = rate
+
+	return nil
+}
+
+func (s *Service) ChargeOverdraftInterest() ([]types.Payment, error) {
+	now := s.now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if s.lastInterestCharge.Equal(day) {
+		return nil, ErrInterestAlreadyCharged
+	}
+	s.lastInterestCharge = day
+
+	payments := []types.Payment{}
+	for _, account := range s.accounts {
+		if account.Balance >= 0 || account.OverdraftRate == 0 {
+			continue
+		}
+
+		fee := -account.Balance * types.Money(account.OverdraftRate) / basisPoints
+		if fee <= 0 {
+			continue
+		}
+
+		account.Balance -= fee
+
+		payment := &types.Payment{
+			ID:        uuid.New().String(),
+			AccountID: account.ID,
+			Amount:    fee,
+			Category:  types.PaymentCategoryOverdraftFee,
+			Status:    types.PaymentStatusInProgress,
+		}
+
+		s.addPayment(payment)
+		payments = append(payments, *payment)
+	}
+
+	return payments, nil
+}
+
+func (s *Service) OutstandingDebt(accountID int64) (types.Money, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	if account.Balance >= 0 {
+		return 0, nil
+	}
+
+	return -account.Balance, nil
+}
+
+func (s *Service) DebtReport() []types.Debt {
+	debts := []types.Debt{}
+	for _, account := range s.accounts {
+		if account.Balance >= 0 {
+			continue
+		}
+
+		debts = append(debts, types.Debt{
+			AccountID:   account.ID,
+			Phone:       account.Phone,
+			Debt:        -account.Balance,
+			CreditLimit: account.CreditLimit,
+		})
+	}
+
+	return debts
+}
+
+func availableBalance(account *types.Account) types.Money {
+	return account.Balance - account.Held + account.CreditLimit
+}
@@ -0,0 +1,140 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_Pay_creditLine(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Pay(account.ID, 200, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	err = svc.SetCreditLine(account.ID, 500, 10)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Pay(account.ID, 600, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != -500 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+
+	_, err = svc.Pay(account.ID, 1, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	debt, err := svc.OutstandingDebt(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if debt != 500 {
+		t.Errorf("invalid debt, got %v", debt)
+	}
+
+	err = svc.SetCreditLine(account.ID, -1, 0)
+	if err != ErrInvalidLimit {
+		t.Error(err)
+	}
+}
+
+func TestService_ChargeOverdraftInterest(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	debtor, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(other.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetCreditLine(debtor.ID, 100_000, 50)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Pay(debtor.ID, 10_000, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	fees, err := svc.ChargeOverdraftInterest()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(fees) != 1 || fees[0].AccountID != debtor.ID || fees[0].Amount != 50 {
+		t.Errorf("invalid fees, got %v", fees)
+	}
+
+	if fees[0].Category != types.PaymentCategoryOverdraftFee || debtor.Balance != -10_050 {
+		t.Errorf("invalid balance, got %v", debtor.Balance)
+	}
+
+	_, err = svc.ChargeOverdraftInterest()
+	if err != ErrInterestAlreadyCharged {
+		t.Error(err)
+	}
+
+	report := svc.DebtReport()
+	if len(report) != 1 || report[0].Debt != 10_050 || report[0].CreditLimit != 100_000 {
+		t.Errorf("invalid report, got %v", report)
+	}
+
+	now = now.Add(24 * time.Hour)
+
+	fees, err = svc.ChargeOverdraftInterest()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(fees) != 1 || fees[0].Amount != 50 {
+		t.Errorf("invalid fees, got %v", fees)
+	}
+}
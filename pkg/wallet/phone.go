@@ -0,0 +1,78 @@
+package wallet
+
+import (
+	"strings"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+type countryCode struct {
+	code           string
+	nationalLength int
+}
+
+var defaultCountryCodes = []countryCode{
+	{code: "992", nationalLength: 9},
+	{code: "7", nationalLength: 10},
+	{code: "998", nationalLength: 9},
+}
+
+const (
+	minPhoneDigits = 8
+	maxPhoneDigits = 15
+)
+
+func NormalizePhone(phone types.Phone) (types.Phone, error) {
+	raw := strings.TrimSpace(string(phone))
+	raw = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "").Replace(raw)
+
+	international := false
+	switch {
+	case strings.HasPrefix(raw, "+"):
+		international = true
+		raw = raw[1:]
+	case strings.HasPrefix(raw, "00"):
+		international = true
+		raw = raw[2:]
+	}
+
+	if raw == "" {
+		return "", ErrInvalidPhone
+	}
+
+	for _, digit := range raw {
+		if digit < '0' || digit > '9' {
+			return "", ErrInvalidPhone
+		}
+	}
+
+	if international {
+		return normalizeInternational(raw)
+	}
+
+	for _, country := range defaultCountryCodes {
+		if len(raw) == len(country.code)+country.nationalLength && strings.HasPrefix(raw, country.code) {
+			return types.Phone("+" + raw), nil
+		}
+
+		if len(raw) == country.nationalLength {
+			return types.Phone("+" + country.code + raw), nil
+		}
+	}
+
+	return "", ErrInvalidPhone
+}
+
+func normalizeInternational(digits string) (types.Phone, error) {
+	if len(digits) < minPhoneDigits || len(digits) > maxPhoneDigits || digits[0] == '0' {
+		return "", ErrInvalidPhone
+	}
+
+	for _, country := range defaultCountryCodes {
+		if strings.HasPrefix(digits, country.code) && len(digits) != len(country.code)+country.nationalLength {
+			return "", ErrInvalidPhone
+		}
+	}
+
+	return types.Phone("+" + digits), nil
+}
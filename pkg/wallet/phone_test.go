@@ -0,0 +1,153 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestNormalizePhone(t *testing.T) {
+	tests := []struct {
+		phone types.Phone
+		want  types.Phone
+		err   error
+	}{
+		{phone: "+992935626274", want: "+992935626274"},
+		{phone: "992 93 562 6274", want: "+992935626274"},
+		{phone: "935626274", want: "+992935626274"},
+		{phone: "00992935626274", want: "+992935626274"},
+		{phone: "(93) 562-62-74", want: "+992935626274"},
+		{phone: "9161234567", want: "+79161234567"},
+		{phone: "+79161234567", want: "+79161234567"},
+		{phone: "+4915112345678", want: "+4915112345678"},
+		{phone: "", err: ErrInvalidPhone},
+		{phone: "+", err: ErrInvalidPhone},
+		{phone: "93562627a", err: ErrInvalidPhone},
+		{phone: "12345", err: ErrInvalidPhone},
+		{phone: "+99293562627", err: ErrInvalidPhone},
+		{phone: "+0992935626274", err: ErrInvalidPhone},
+		{phone: "+1234567890123456", err: ErrInvalidPhone},
+	}
+
+	for _, test := range tests {
+		got, err := NormalizePhone(test.phone)
+		if err != test.err {
+			t.Errorf("NormalizePhone(%v), got error %v, want %v", test.phone, err, test.err)
+			continue
+		}
+
+		if got != test.want {
+			t.Errorf("NormalizePhone(%v), got %v, want %v", test.phone, got, test.want)
+		}
+	}
+}
+
+func TestService_RegisterAccount_normalizedDuplicates(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992935626274")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Phone != "+992935626274" {
+		t.Errorf("invalid phone, got %v", account.Phone)
+	}
+
+	_, err = svc.RegisterAccount("992 93 562 6274")
+	if err != ErrPhoneNumberRegistred {
+		t.Error(err)
+	}
+
+	_, err = svc.RegisterAccount("935626274")
+	if err != ErrPhoneNumberRegistred {
+		t.Error(err)
+	}
+
+	_, err = svc.RegisterAccount("not a phone")
+	if err != ErrInvalidPhone {
+		t.Error(err)
+	}
+
+	found, err := svc.FindAccountByPhone("93 562 62 74")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if found.ID != account.ID {
+		t.Errorf("invalid account, got %v", found.ID)
+	}
+}
+
+func TestService_ImportFromFile_normalizesPhone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/accounts.txt"
+	err = ioutil.WriteFile(path, []byte("1;992 93 562 6274;100|2;12345;200|"), 0666)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	svc := &Service{}
+	err = svc.ImportFromFile(path)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	account, err := svc.FindAccountByPhone("+992935626274")
+	if err != nil || account.ID != 1 {
+		t.Errorf("imported phone must be normalized, got %v, %v", account, err)
+	}
+
+	_, err = svc.RegisterAccount("+992935626274")
+	if err != ErrPhoneNumberRegistred {
+		t.Error(err)
+	}
+
+	_, err = svc.FindAccountByID(2)
+	if err != ErrAccountNotFound {
+		t.Errorf("invalid phone must be skipped, got %v", err)
+	}
+}
+
+func TestService_Import_normalizesPhone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	err = ioutil.WriteFile(dir+"/accounts.dump", []byte("1;12345;100\n2;935626274;200\n"), 0666)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	svc := &Service{}
+	err = svc.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	account, err := svc.FindAccountByPhone("+992935626274")
+	if err != nil || account.Balance != 200 {
+		t.Errorf("imported phone must be normalized, got %v, %v", account, err)
+	}
+
+	if len(svc.accounts) != 1 {
+		t.Errorf("invalid phone must be skipped, got %v accounts", len(svc.accounts))
+	}
+}
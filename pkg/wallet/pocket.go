@@ -0,0 +1,152 @@
+package wallet
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func (s *Service) MoveToPocket(accountID int64, name string, amount types.Money) error {
+	if amount <= 0 {
+		return ErrAmountMustBePositive
+	}
+
+	if !validPocketName(name) {
+		return ErrInvalidPocketName
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	if account.Balance-account.Held < amount {
+		return ErrNotEnoughBalance
+	}
+
+	if account.Pockets == nil {
+		account.Pockets = make(map[string]types.Money)
+	}
+
+	account.Balance -= amount
+	account.Pockets[name] += amount
+
+	return nil
+}
+
+func (s *Service) MoveFromPocket(accountID int64, name string, amount types.Money) error {
+	if amount <= 0 {
+		return ErrAmountMustBePositive
+	}
+
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	balance, ok := account.Pockets[name]
+	if !ok {
+		return ErrPocketNotFound
+	}
+
+	if balance < amount {
+		return ErrNotEnoughPocketBalance
+	}
+
+	account.Pockets[name] -= amount
+	account.Balance += amount
+
+	return nil
+}
+
+func (s *Service) TotalBalance(accountID int64) (types.Money, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return 0, err
+	}
+
+	return totalBalance(account), nil
+}
+
+func totalBalance(account *types.Account) types.Money {
+	total := account.Balance
+	for _, balance := range account.Pockets {
+		total += balance
+	}
+
+	return total
+}
+
+func copyPockets(pockets map[string]types.Money) map[string]types.Money {
+	if pockets == nil {
+		return nil
+	}
+
+	result := make(map[string]types.Money, len(pockets))
+	for name, balance := range pockets {
+		result[name] = balance
+	}
+
+	return result
+}
+
+func emptyPockets(account *types.Account) {
+	for name, balance := range account.Pockets {
+		account.Balance += balance
+		account.Pockets[name] = 0
+	}
+}
+
+func validPocketName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, ";,=\n|")
+}
+
+func pocketsToString(pockets map[string]types.Money) string {
+	names := make([]string, 0, len(pockets))
+	for name := range pockets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []string{}
+	for _, name := range names {
+		result = append(result, name+"="+strconv.Itoa(int(pockets[name])))
+	}
+
+	return strings.Join(result, ",")
+}
+
+func parsePockets(data string) (map[string]types.Money, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	pockets := make(map[string]types.Money)
+	for _, pair := range strings.Split(data, ",") {
+		i := strings.LastIndex(pair, "=")
+		if i < 0 {
+			return nil, ErrInvalidPocketName
+		}
+
+		balance, err := strconv.Atoi(pair[i+1:])
+		if err != nil {
+			return nil, err
+		}
+
+		pockets[pair[:i]] = types.Money(balance)
+	}
+
+	return pockets, nil
+}
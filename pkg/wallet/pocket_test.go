@@ -0,0 +1,224 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestService_Pockets(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.MoveToPocket(account.ID, "rent", 600)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.MoveToPocket(account.ID, "vacation", 500)
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	err = svc.MoveToPocket(account.ID, "bad;name", 100)
+	if err != ErrInvalidPocketName {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, 500, "auto")
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	total, err := svc.TotalBalance(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if total != 1000 || account.Balance != 400 || account.Pockets["rent"] != 600 {
+		t.Errorf("invalid balances, total %v, main %v, pockets %v", total, account.Balance, account.Pockets)
+	}
+
+	err = svc.MoveFromPocket(account.ID, "rent", 700)
+	if err != ErrNotEnoughPocketBalance {
+		t.Error(err)
+	}
+
+	err = svc.MoveFromPocket(account.ID, "vacation", 100)
+	if err != ErrPocketNotFound {
+		t.Error(err)
+	}
+
+	err = svc.MoveFromPocket(account.ID, "rent", 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 600 || account.Pockets["rent"] != 400 {
+		t.Errorf("invalid balances, main %v, pockets %v", account.Balance, account.Pockets)
+	}
+
+	_, err = svc.Pay(account.ID, 600, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.CloseAccount(account.ID)
+	if err != ErrBalanceNotZero {
+		t.Error(err)
+	}
+}
+
+func TestService_ExportImport_pockets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	err = svc.MoveToPocket(account.ID, "rent", 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.MoveToPocket(account.ID, "vacation", 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindAccountByPhone(account.Phone)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.Balance != 500 || got.Pockets["rent"] != 300 || got.Pockets["vacation"] != 200 {
+		t.Errorf("invalid account, got %v", got)
+	}
+
+	total, err := restored.TotalBalance(got.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if total != 1000 {
+		t.Errorf("invalid total, got %v", total)
+	}
+}
+
+func TestService_CloseAccountWithPayout_pockets(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.MoveToPocket(account.ID, "rent", 600)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.FreezeCard(card.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.CloseAccountWithPayout(account.ID, card.ID)
+	if err != ErrCardFrozen {
+		t.Error(err)
+	}
+
+	_, err = svc.CloseAccountWithPayout(account.ID, card.ID+1)
+	if err != ErrCardNotFound {
+		t.Error(err)
+	}
+
+	other, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	foreign, err := svc.IssueCard(other.ID, "5555555555554444", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.CloseAccountWithPayout(account.ID, foreign.ID)
+	if err != ErrCardNotLinked {
+		t.Error(err)
+	}
+
+	_, err = svc.Authorize(account.ID, 100, "hotel")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.CloseAccountWithPayout(account.ID, card.ID)
+	if err != ErrAccountHasHolds {
+		t.Error(err)
+	}
+
+	if account.Balance != 400 || account.Pockets["rent"] != 600 {
+		t.Errorf("failed payout must keep pockets, got %v, %v", account.Balance, account.Pockets)
+	}
+}
@@ -0,0 +1,263 @@
+package wallet
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func (s *Service) SetFavoriteRecurrence(favoriteID string, rule types.Recurrence) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	if rule.Policy == "" {
+		rule.Policy = types.RecurrencePolicySkip
+	}
+
+	err = validateRecurrence(rule)
+	if err != nil {
+		return err
+	}
+
+	next, err := nextRecurrence(rule, s.now())
+	if err != nil {
+		return err
+	}
+
+	rule.NextRunAt = next
+	rule.Retries = 0
+	favorite.Recurrence = &rule
+
+	return nil
+}
+
+func (s *Service) ClearFavoriteRecurrence(favoriteID string) error {
+	favorite, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return err
+	}
+
+	favorite.Recurrence = nil
+	return nil
+}
+
+func (s *Service) RunDueFavorites() []types.RecurringRun {
+	now := s.now()
+
+	runs := []types.RecurringRun{}
+	for _, favorite := range s.favorites {
+		rule := favorite.Recurrence
+		if rule == nil || rule.NextRunAt.After(now) {
+			continue
+		}
+
+		run := types.RecurringRun{
+			FavoriteID:  favorite.ID,
+			ScheduledAt: rule.NextRunAt,
+			RanAt:       now,
+		}
+
+		payment, err := s.PayFromFavorite(favorite.ID)
+		switch {
+		case err == nil:
+			run.Status = types.RecurringRunOk
+			run.PaymentID = payment.ID
+			rule.Retries = 0
+		case err == ErrNotEnoughBalance && rule.Policy == types.RecurrencePolicyRetry && rule.Retries < rule.MaxRetries:
+			run.Status = types.RecurringRunRetry
+			run.Error = err.Error()
+			rule.Retries++
+			rule.NextRunAt = now.Add(rule.RetryInterval)
+		case err == ErrNotEnoughBalance:
+			run.Status = types.RecurringRunSkipped
+			run.Error = err.Error()
+			rule.Retries = 0
+		default:
+			run.Status = types.RecurringRunFail
+			run.Error = err.Error()
+			rule.Retries = 0
+		}
+
+		if run.Status != types.RecurringRunRetry {
+			next, err := nextRecurrence(*rule, now)
+			if err != nil {
+				favorite.Recurrence = nil
+			} else {
+				rule.NextRunAt = next
+			}
+		}
+
+		if s.recurringRuns == nil {
+			s.recurringRuns = make(map[string][]types.RecurringRun)
+		}
+		s.recurringRuns[favorite.ID] = append(s.recurringRuns[favorite.ID], run)
+		runs = append(runs, run)
+	}
+
+	return runs
+}
+
+func (s *Service) RecurringRuns(favoriteID string) ([]types.RecurringRun, error) {
+	_, err := s.FindFavoriteByID(favoriteID)
+	if err != nil {
+		return nil, err
+	}
+
+	runs := make([]types.RecurringRun, len(s.recurringRuns[favoriteID]))
+	copy(runs, s.recurringRuns[favoriteID])
+
+	return runs, nil
+}
+
+func validateRecurrence(rule types.Recurrence) error {
+	switch rule.Frequency {
+	case types.RecurrenceDaily:
+	case types.RecurrenceWeekly:
+		if rule.Weekday < time.Sunday || rule.Weekday > time.Saturday {
+			return ErrInvalidRecurrence
+		}
+	case types.RecurrenceMonthly:
+		if rule.DayOfMonth < 1 || rule.DayOfMonth > 31 {
+			return ErrInvalidRecurrence
+		}
+	case types.RecurrenceCron:
+		_, err := parseCron(rule.Cron)
+		if err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidRecurrence
+	}
+
+	switch rule.Policy {
+	case types.RecurrencePolicySkip:
+	case types.RecurrencePolicyRetry:
+		if rule.MaxRetries <= 0 || rule.RetryInterval <= 0 {
+			return ErrInvalidRecurrence
+		}
+	default:
+		return ErrInvalidRecurrence
+	}
+
+	return nil
+}
+
+func nextRecurrence(rule types.Recurrence, after time.Time) (time.Time, error) {
+	year, month, day := after.Date()
+	location := after.Location()
+
+	switch rule.Frequency {
+	case types.RecurrenceDaily:
+		return time.Date(year, month, day+1, 0, 0, 0, 0, location), nil
+	case types.RecurrenceWeekly:
+		days := (int(rule.Weekday) - int(after.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return time.Date(year, month, day+days, 0, 0, 0, 0, location), nil
+	case types.RecurrenceMonthly:
+		next := monthDay(year, month, rule.DayOfMonth, location)
+		if !next.After(after) {
+			next = monthDay(year, month+1, rule.DayOfMonth, location)
+		}
+		return next, nil
+	case types.RecurrenceCron:
+		schedule, err := parseCron(rule.Cron)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		next, ok := schedule.next(after)
+		if !ok {
+			return time.Time{}, ErrInvalidRecurrence
+		}
+		return next, nil
+	}
+
+	return time.Time{}, ErrInvalidRecurrence
+}
+
+func monthDay(year int, month time.Month, day int, location *time.Location) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, location).Day()
+	if day > last {
+		day = last
+	}
+
+	return time.Date(year, month, day, 0, 0, 0, 0, location)
+}
+
+func recurrenceToString(rule *types.Recurrence) string {
+	if rule == nil {
+		return ""
+	}
+
+	values := url.Values{}
+	values.Set("frequency", string(rule.Frequency))
+	values.Set("weekday", strconv.Itoa(int(rule.Weekday)))
+	values.Set("day", strconv.Itoa(rule.DayOfMonth))
+	values.Set("cron", rule.Cron)
+	values.Set("policy", string(rule.Policy))
+	values.Set("maxRetries", strconv.Itoa(rule.MaxRetries))
+	values.Set("retryInterval", rule.RetryInterval.String())
+	values.Set("next", strconv.FormatInt(unixNanoOrZero(rule.NextRunAt), 10))
+	values.Set("retries", strconv.Itoa(rule.Retries))
+
+	return values.Encode()
+}
+
+func parseRecurrence(data string) (*types.Recurrence, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	values, err := url.ParseQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	weekday, err := strconv.Atoi(values.Get("weekday"))
+	if err != nil {
+		return nil, err
+	}
+
+	day, err := strconv.Atoi(values.Get("day"))
+	if err != nil {
+		return nil, err
+	}
+
+	maxRetries, err := strconv.Atoi(values.Get("maxRetries"))
+	if err != nil {
+		return nil, err
+	}
+
+	retryInterval, err := time.ParseDuration(values.Get("retryInterval"))
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := strconv.ParseInt(values.Get("next"), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	retries, err := strconv.Atoi(values.Get("retries"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Recurrence{
+		Frequency:     types.RecurrenceFrequency(values.Get("frequency")),
+		Weekday:       time.Weekday(weekday),
+		DayOfMonth:    day,
+		Cron:          values.Get("cron"),
+		Policy:        types.RecurrencePolicy(values.Get("policy")),
+		MaxRetries:    maxRetries,
+		RetryInterval: retryInterval,
+		NextRunAt:     timeFromUnixNano(next),
+		Retries:       retries,
+	}, nil
+}
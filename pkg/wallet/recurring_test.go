@@ -0,0 +1,271 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func newRecurringService(t *testing.T, now *time.Time) (*Service, *types.Account, *types.Favorite) {
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return *now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	account.Balance = 1000
+
+	payment, err := svc.Pay(account.ID, 100, "internet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "internet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return svc, account, favorite
+}
+
+func Test_nextRecurrence(t *testing.T) {
+	after := time.Date(2021, 1, 30, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		rule types.Recurrence
+		want time.Time
+	}{
+		{types.Recurrence{Frequency: types.RecurrenceDaily}, time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceWeekly, Weekday: time.Monday}, time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceWeekly, Weekday: time.Saturday}, time.Date(2021, 2, 6, 0, 0, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceMonthly, DayOfMonth: 31}, time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceMonthly, DayOfMonth: 5}, time.Date(2021, 2, 5, 0, 0, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceCron, Cron: "*/15 * * * *"}, time.Date(2021, 1, 30, 10, 45, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceCron, Cron: "0 9 * * 1-5"}, time.Date(2021, 2, 1, 9, 0, 0, 0, time.UTC)},
+		{types.Recurrence{Frequency: types.RecurrenceCron, Cron: "0 0 29 2 *"}, time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := nextRecurrence(test.rule, after)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if !got.Equal(test.want) {
+			t.Errorf("%v: got %v, want %v", test.rule, got, test.want)
+		}
+	}
+
+	second, err := nextRecurrence(types.Recurrence{Frequency: types.RecurrenceMonthly, DayOfMonth: 31}, time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !second.Equal(time.Date(2021, 2, 28, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("monthly rule must clamp to the last day, got %v", second)
+	}
+}
+
+func TestService_SetFavoriteRecurrence_invalid(t *testing.T) {
+	now := time.Date(2021, 1, 30, 10, 30, 0, 0, time.UTC)
+	svc, _, favorite := newRecurringService(t, &now)
+
+	rules := []types.Recurrence{
+		{Frequency: "HOURLY"},
+		{Frequency: types.RecurrenceMonthly, DayOfMonth: 32},
+		{Frequency: types.RecurrenceCron, Cron: "61 * * * *"},
+		{Frequency: types.RecurrenceCron, Cron: "* * *"},
+		{Frequency: types.RecurrenceCron, Cron: "0 0 30 2 *"},
+		{Frequency: types.RecurrenceDaily, Policy: types.RecurrencePolicyRetry},
+	}
+
+	for _, rule := range rules {
+		err := svc.SetFavoriteRecurrence(favorite.ID, rule)
+		if err != ErrInvalidRecurrence {
+			t.Errorf("%v: got %v", rule, err)
+		}
+	}
+
+	err := svc.SetFavoriteRecurrence("unknown", types.Recurrence{Frequency: types.RecurrenceDaily})
+	if err != ErrFavoriteNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_RunDueFavorites(t *testing.T) {
+	now := time.Date(2021, 1, 30, 10, 30, 0, 0, time.UTC)
+	svc, account, favorite := newRecurringService(t, &now)
+
+	err := svc.SetFavoriteRecurrence(favorite.ID, types.Recurrence{Frequency: types.RecurrenceDaily})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	runs := svc.RunDueFavorites()
+	if len(runs) != 0 {
+		t.Errorf("nothing must be due yet, got %v", runs)
+	}
+
+	now = time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	runs = svc.RunDueFavorites()
+	if len(runs) != 1 || runs[0].Status != types.RecurringRunOk || runs[0].PaymentID == "" {
+		t.Errorf("favorite must be paid, got %v", runs)
+	}
+
+	if account.Balance != 800 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+
+	if !favorite.Recurrence.NextRunAt.Equal(time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("invalid next run, got %v", favorite.Recurrence.NextRunAt)
+	}
+
+	account.Balance = 0
+	now = time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	runs = svc.RunDueFavorites()
+	if len(runs) != 1 || runs[0].Status != types.RecurringRunSkipped {
+		t.Errorf("favorite must be skipped, got %v", runs)
+	}
+
+	if !favorite.Recurrence.NextRunAt.Equal(time.Date(2021, 2, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("skipped run must move to the next day, got %v", favorite.Recurrence.NextRunAt)
+	}
+
+	history, err := svc.RecurringRuns(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(history) != 2 {
+		t.Errorf("invalid history, got %v", history)
+	}
+}
+
+func TestService_RunDueFavorites_retry(t *testing.T) {
+	now := time.Date(2021, 1, 30, 10, 30, 0, 0, time.UTC)
+	svc, account, favorite := newRecurringService(t, &now)
+
+	err := svc.SetFavoriteRecurrence(favorite.ID, types.Recurrence{
+		Frequency:     types.RecurrenceDaily,
+		Policy:        types.RecurrencePolicyRetry,
+		MaxRetries:    2,
+		RetryInterval: time.Hour,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	account.Balance = 0
+	want := []types.RecurringRunStatus{
+		types.RecurringRunRetry,
+		types.RecurringRunRetry,
+		types.RecurringRunSkipped,
+	}
+
+	now = time.Date(2021, 1, 31, 0, 0, 0, 0, time.UTC)
+	for i, status := range want {
+		runs := svc.RunDueFavorites()
+		if len(runs) != 1 || runs[0].Status != status {
+			t.Errorf("run %v: got %v, want %v", i, runs, status)
+			return
+		}
+		now = now.Add(time.Hour)
+	}
+
+	if !favorite.Recurrence.NextRunAt.Equal(time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("invalid next run, got %v", favorite.Recurrence.NextRunAt)
+	}
+
+	err = svc.SetFavoriteRecurrence(favorite.ID, types.Recurrence{
+		Frequency:     types.RecurrenceDaily,
+		Policy:        types.RecurrencePolicyRetry,
+		MaxRetries:    2,
+		RetryInterval: time.Hour,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	now = time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC)
+	runs := svc.RunDueFavorites()
+	if len(runs) != 1 || runs[0].Status != types.RecurringRunRetry {
+		t.Errorf("invalid run, got %v", runs)
+		return
+	}
+
+	account.Balance = 1000
+	now = now.Add(time.Hour)
+	runs = svc.RunDueFavorites()
+	if len(runs) != 1 || runs[0].Status != types.RecurringRunOk {
+		t.Errorf("retry must succeed, got %v", runs)
+	}
+
+	if favorite.Recurrence.Retries != 0 {
+		t.Errorf("retries must be reset, got %v", favorite.Recurrence.Retries)
+	}
+}
+
+func TestService_Recurrence_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2021, 1, 30, 10, 30, 0, 0, time.UTC)
+	svc, _, favorite := newRecurringService(t, &now)
+
+	err = svc.SetFavoriteRecurrence(favorite.ID, types.Recurrence{
+		Frequency:     types.RecurrenceCron,
+		Cron:          "0 9 1,15 * *",
+		Policy:        types.RecurrencePolicyRetry,
+		MaxRetries:    3,
+		RetryInterval: 30 * time.Minute,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindFavoriteByID(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if got.Recurrence == nil || !got.Recurrence.NextRunAt.Equal(favorite.Recurrence.NextRunAt) {
+		t.Errorf("invalid recurrence, got %v", got.Recurrence)
+		return
+	}
+
+	got.Recurrence.NextRunAt = favorite.Recurrence.NextRunAt
+	if *got.Recurrence != *favorite.Recurrence {
+		t.Errorf("invalid recurrence, got %v, want %v", got.Recurrence, favorite.Recurrence)
+	}
+}
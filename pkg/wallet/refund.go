@@ -0,0 +1,113 @@
+package wallet
+
+import (
+	"github.com/google/uuid"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func (s *Service) Refund(paymentID string, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	payment, account, err := s.findPaymentAndAccountByPaymentID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !refundable(payment) || s.hasClaim(payment.ID) {
+		return nil, ErrRefundNotAllowed
+	}
+
+	if payment.Status != types.PaymentStatusInProgress && payment.Status != types.PaymentStatusOk {
+		return nil, ErrRefundNotAllowed
+	}
+
+	refunded := s.refundedAmount(payment.ID)
+	if refunded+amount > payment.Amount {
+		return nil, ErrRefundExceedsPayment
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	refund := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: account.ID,
+		Amount:    amount,
+		Category:  types.PaymentCategoryRefund,
+		Status:    types.PaymentStatusOk,
+		RefundOf:  payment.ID,
+	}
+
+	if payment.CardID != 0 {
+		card, err := s.FindCardByID(payment.CardID)
+		if err != nil {
+			return nil, err
+		}
+
+		card.Balance += amount
+		refund.ToCardID = card.ID
+	} else {
+		err = s.checkDepositLimits(account, amount)
+		if err != nil {
+			return nil, err
+		}
+
+		account.Balance += amount
+		refund.ToAccountID = account.ID
+	}
+
+	s.addPayment(refund)
+
+	if refunded+amount == payment.Amount {
+		s.setStatus(payment, types.PaymentStatusRefunded)
+	}
+
+	return refund, nil
+}
+
+func (s *Service) FindRefundsByPaymentID(paymentID string) ([]types.Payment, error) {
+	_, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds := []types.Payment{}
+	for _, refund := range s.refundsByPayment[paymentID] {
+		refunds = append(refunds, *refund)
+	}
+
+	return refunds, nil
+}
+
+func refundable(payment *types.Payment) bool {
+	switch payment.Category {
+	case types.PaymentCategoryTransferIn, types.PaymentCategoryCardDeposit, types.PaymentCategoryRefund:
+		return false
+	}
+
+	return payment.RefundOf == "" && payment.LinkedID == "" && payment.ToAccountID == 0 && payment.ToCardID == 0
+}
+
+func (s *Service) hasClaim(paymentID string) bool {
+	for _, claim := range s.claims {
+		if claim.PaymentID == paymentID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Service) refundedAmount(paymentID string) types.Money {
+	var total types.Money
+	for _, refund := range s.refundsByPayment[paymentID] {
+		total += refund.Amount
+	}
+
+	return total
+}
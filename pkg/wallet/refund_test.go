@@ -0,0 +1,403 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_Refund_partial(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Pay(account.ID, 500, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	refund, err := svc.Refund(payment.ID, 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if refund.RefundOf != payment.ID || refund.Category != types.PaymentCategoryRefund || account.Balance != 700 {
+		t.Errorf("invalid refund, got %v, balance %v", refund, account.Balance)
+	}
+
+	_, err = svc.Refund(payment.ID, 301)
+	if err != ErrRefundExceedsPayment {
+		t.Error(err)
+	}
+
+	_, err = svc.Refund(refund.ID, 100)
+	if err != ErrRefundNotAllowed {
+		t.Error(err)
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Balance != 1000 {
+		t.Errorf("reject must return only the remaining amount, got %v", account.Balance)
+	}
+
+	history, err := svc.ExportAccountHistory(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(history) != 2 {
+		t.Errorf("invalid history, got %v", history)
+	}
+}
+
+func TestService_Refund_full(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Pay(account.ID, 500, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Complete(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 400)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Status != types.PaymentStatusRefunded || account.Balance != 1000 {
+		t.Errorf("invalid refund, status %v, balance %v", payment.Status, account.Balance)
+	}
+
+	_, err = svc.Refund(payment.ID, 1)
+	if err != ErrRefundNotAllowed {
+		t.Error(err)
+	}
+
+	refunds, err := svc.FindRefundsByPaymentID(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(refunds) != 2 {
+		t.Errorf("invalid refunds, got %v", refunds)
+	}
+}
+
+func TestService_Refund_transfer(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	debit, err := svc.Transfer(from.ID, to.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(debit.ID, 50)
+	if err != ErrRefundNotAllowed {
+		t.Error(err)
+	}
+}
+
+func TestService_Refund_transferIn(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	debit, err := svc.Transfer(from.ID, to.ID, 500)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(debit.LinkedID, 500)
+	if err != ErrRefundNotAllowed {
+		t.Error(err)
+	}
+
+	if from.Balance != 500 || to.Balance != 500 {
+		t.Errorf("invalid balances, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_Refund_payByPhone(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	payment, err := svc.PayByPhone(from.ID, "+992000000001", 500)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 500)
+	if err != ErrRefundNotAllowed {
+		t.Errorf("payment with pending claim must not be refunded, got %v", err)
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 500)
+	if err != ErrRefundNotAllowed {
+		t.Errorf("linked payment must not be refunded, got %v", err)
+	}
+
+	if from.Balance != 500 || to.Balance != 500 {
+		t.Errorf("invalid balances, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_Refund_cardDeposit(t *testing.T) {
+	svc := newCardService(t)
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	card, err := svc.IssueCard(account.ID, "4111111111111111", "TJS")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	card.Balance = 1000
+
+	payment, err := svc.DepositFromCard(account.ID, card.ID, 400)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 400)
+	if err != ErrRefundNotAllowed {
+		t.Error(err)
+	}
+
+	if account.Balance != 400 || card.Balance != 600 {
+		t.Errorf("invalid balances, account %v, card %v", account.Balance, card.Balance)
+	}
+}
+
+func TestService_Refund_reimport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	first, err := svc.Pay(account.ID, 300, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	second, err := svc.Pay(account.ID, 300, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	refund, err := svc.Refund(first.ID, 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	svc.setPaymentRefundOf(refund, second.ID)
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if restored.refundedAmount(first.ID) != 0 || restored.refundedAmount(second.ID) != 200 {
+		t.Errorf("invalid refunded amounts, got %v, %v", restored.refundedAmount(first.ID), restored.refundedAmount(second.ID))
+	}
+
+	refunds, err := restored.FindRefundsByPaymentID(second.ID)
+	if err != nil || len(refunds) != 1 || refunds[0].ID != refund.ID {
+		t.Errorf("invalid refunds, got %v, %v", refunds, err)
+	}
+}
+
+func TestService_Refund_closedAccount(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 500
+
+	payment, err := svc.Pay(account.ID, 500, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.CloseAccount(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 200)
+	if err != ErrAccountClosed {
+		t.Error(err)
+	}
+
+	if account.Balance != 0 {
+		t.Errorf("closed account balance must stay zero, got %v", account.Balance)
+	}
+}
+
+func TestService_Refund_depositLimits(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Pay(account.ID, 500, "shop")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.SetTierPolicy(types.KYCLevelAnonymous, TierLimits{MaxBalance: 600})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Refund(payment.ID, 200)
+	if err != ErrKYCBalanceLimitExceeded {
+		t.Error(err)
+	}
+
+	_, err = svc.Refund(payment.ID, 100)
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,199 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func (s *Service) SchedulePayment(accountID int64, amount types.Money, category types.PaymentCategory, executeAt time.Time) (*types.ScheduledPayment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !executeAt.After(s.now()) {
+		return nil, ErrScheduleInPast
+	}
+
+	schedule := &types.ScheduledPayment{
+		ID:        uuid.New().String(),
+		AccountID: accountID,
+		Amount:    amount,
+		Category:  category,
+		ExecuteAt: executeAt,
+		Status:    types.ScheduleStatusPending,
+	}
+
+	s.schedules = append(s.schedules, schedule)
+	return schedule, nil
+}
+
+func (s *Service) FindScheduleByID(scheduleID string) (*types.ScheduledPayment, error) {
+	for _, schedule := range s.schedules {
+		if schedule.ID == scheduleID {
+			return schedule, nil
+		}
+	}
+
+	return nil, ErrScheduleNotFound
+}
+
+func (s *Service) PendingSchedules(accountID int64) ([]types.ScheduledPayment, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	schedules := []types.ScheduledPayment{}
+	for _, schedule := range s.schedules {
+		if schedule.AccountID == accountID && schedule.Status == types.ScheduleStatusPending {
+			schedules = append(schedules, *schedule)
+		}
+	}
+
+	sort.SliceStable(schedules, func(i, j int) bool {
+		return schedules[i].ExecuteAt.Before(schedules[j].ExecuteAt)
+	})
+
+	return schedules, nil
+}
+
+func (s *Service) CancelSchedule(scheduleID string) error {
+	schedule, err := s.FindScheduleByID(scheduleID)
+	if err != nil {
+		return err
+	}
+
+	if schedule.Status != types.ScheduleStatusPending {
+		return ErrScheduleNotPending
+	}
+
+	schedule.Status = types.ScheduleStatusCancelled
+	return nil
+}
+
+func (s *Service) RunDueSchedules() []types.ScheduledPayment {
+	now := s.now()
+
+	due := []*types.ScheduledPayment{}
+	for _, schedule := range s.schedules {
+		if schedule.Status == types.ScheduleStatusPending && !schedule.ExecuteAt.After(now) {
+			due = append(due, schedule)
+		}
+	}
+
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].ExecuteAt.Before(due[j].ExecuteAt)
+	})
+
+	executed := []types.ScheduledPayment{}
+	for _, schedule := range due {
+		payment, err := s.Pay(schedule.AccountID, schedule.Amount, schedule.Category)
+		if err != nil {
+			schedule.Status = types.ScheduleStatusFailed
+			schedule.Error = err.Error()
+		} else {
+			schedule.Status = types.ScheduleStatusExecuted
+			schedule.PaymentID = payment.ID
+		}
+		executed = append(executed, *schedule)
+	}
+
+	return executed
+}
+
+func schedulesToString(schedules []*types.ScheduledPayment) string {
+	result := ""
+	for _, schedule := range schedules {
+		result += schedule.ID + ";"
+		result += strconv.Itoa(int(schedule.AccountID)) + ";"
+		result += strconv.Itoa(int(schedule.Amount)) + ";"
+		result += string(schedule.Category) + ";"
+		result += strconv.FormatInt(unixNanoOrZero(schedule.ExecuteAt), 10) + ";"
+		result += string(schedule.Status) + ";"
+		result += schedule.PaymentID + ";"
+		result += url.QueryEscape(schedule.Error) + "\n"
+	}
+
+	return result
+}
+
+func (s *Service) actionBySchedules(path string) error {
+	byteData, err := ioutil.ReadFile(path)
+	if err == nil {
+		datas := string(byteData)
+		splits := strings.Split(datas, "\n")
+
+		for _, split := range splits {
+			if len(split) == 0 {
+				break
+			}
+
+			data := strings.Split(split, ";")
+			id := data[0]
+
+			accountID, err := strconv.Atoi(data[1])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			amount, err := strconv.Atoi(data[2])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			category := types.PaymentCategory(data[3])
+
+			executeAt, err := strconv.ParseInt(data[4], 10, 64)
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			status := types.ScheduleStatus(data[5])
+			paymentID := data[6]
+
+			message := ""
+			if len(data) > 7 {
+				message, err = url.QueryUnescape(data[7])
+				if err != nil {
+					log.Println(err)
+					return err
+				}
+			}
+
+			schedule, err := s.FindScheduleByID(id)
+			if err != nil {
+				schedule = &types.ScheduledPayment{ID: id}
+				s.schedules = append(s.schedules, schedule)
+			}
+
+			schedule.AccountID = int64(accountID)
+			schedule.Amount = types.Money(amount)
+			schedule.Category = category
+			schedule.ExecuteAt = timeFromUnixNano(executeAt)
+			schedule.Status = status
+			schedule.PaymentID = paymentID
+			schedule.Error = message
+		}
+	} else {
+		log.Println(ErrFileNotFound.Error())
+	}
+
+	return nil
+}
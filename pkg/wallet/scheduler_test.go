@@ -0,0 +1,195 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_RunDueSchedules(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 150
+
+	_, err = svc.SchedulePayment(account.ID, 100, "auto", now)
+	if err != ErrScheduleInPast {
+		t.Error(err)
+	}
+
+	first, err := svc.SchedulePayment(account.ID, 100, "auto", now.Add(24*time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	second, err := svc.SchedulePayment(account.ID, 100, "auto", now.Add(48*time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	executed := svc.RunDueSchedules()
+	if len(executed) != 0 {
+		t.Errorf("nothing must be due yet, got %v", executed)
+	}
+
+	now = now.Add(72 * time.Hour)
+	executed = svc.RunDueSchedules()
+	if len(executed) != 2 {
+		t.Errorf("invalid executed schedules, got %v", executed)
+		return
+	}
+
+	if first.Status != types.ScheduleStatusExecuted || first.PaymentID == "" {
+		t.Errorf("first schedule must be executed, got %v", first)
+	}
+
+	if second.Status != types.ScheduleStatusFailed || second.Error != ErrNotEnoughBalance.Error() {
+		t.Errorf("second schedule must fail, got %v", second)
+	}
+
+	if account.Balance != 50 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+
+	executed = svc.RunDueSchedules()
+	if len(executed) != 0 {
+		t.Errorf("schedules must run once, got %v", executed)
+	}
+}
+
+func TestService_CancelSchedule(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	late, err := svc.SchedulePayment(account.ID, 100, "auto", now.Add(48*time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	early, err := svc.SchedulePayment(account.ID, 200, "auto", now.Add(24*time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	pending, err := svc.PendingSchedules(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(pending) != 2 || pending[0].ID != early.ID || pending[1].ID != late.ID {
+		t.Errorf("invalid pending schedules, got %v", pending)
+	}
+
+	err = svc.CancelSchedule(early.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.CancelSchedule(early.ID)
+	if err != ErrScheduleNotPending {
+		t.Error(err)
+	}
+
+	err = svc.CancelSchedule("unknown")
+	if err != ErrScheduleNotFound {
+		t.Error(err)
+	}
+
+	now = now.Add(72 * time.Hour)
+	executed := svc.RunDueSchedules()
+	if len(executed) != 1 || executed[0].ID != late.ID {
+		t.Errorf("cancelled schedule must not run, got %v", executed)
+	}
+
+	if account.Balance != 900 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+}
+
+func TestService_Schedules_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	schedule, err := svc.SchedulePayment(account.ID, 100, "auto", now.Add(time.Hour))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	now = now.Add(2 * time.Hour)
+	svc.RunDueSchedules()
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindScheduleByID(schedule.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !got.ExecuteAt.Equal(schedule.ExecuteAt) {
+		t.Errorf("invalid execute time, got %v, want %v", got.ExecuteAt, schedule.ExecuteAt)
+	}
+
+	got.ExecuteAt = schedule.ExecuteAt
+	if *got != *schedule {
+		t.Errorf("invalid schedule, got %v, want %v", got, schedule)
+	}
+}
@@ -5,13 +5,16 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/shuhrat-shokirov/wallet/pkg/types"
+	"github.com/shuhrat-shokirov/wallet/pkg/vault"
 )
 
 type Error string
@@ -28,6 +31,52 @@ var (
 	ErrPaymentNotFound      = errors.New("payment not found")
 	ErrFavoriteNotFound     = errors.New("favorite not found")
 	ErrFileNotFound         = errors.New("file not found")
+	ErrCardNotFound         = errors.New("card not found")
+	ErrCardNotLinked        = errors.New("card is not linked to account")
+	ErrNotEnoughCardBalance = errors.New("not enough card balance")
+	ErrInvalidPAN           = errors.New("invalid card number")
+	ErrInvalidPANLength     = errors.New("invalid card number length")
+	ErrUnknownIssuer        = errors.New("unknown card issuer")
+	ErrSameCard             = errors.New("source and destination cards are the same")
+	ErrCardFrozen           = errors.New("card is frozen")
+	ErrCardBlocked          = errors.New("card is blocked")
+	ErrCardExpired          = errors.New("card is expired")
+	ErrInvalidLimit         = errors.New("invalid limit")
+	ErrVaultNotConfigured   = errors.New("card vault is not configured")
+	ErrSameAccount          = errors.New("source and destination accounts are the same")
+	ErrClaimNotFound        = errors.New("claim not found")
+	ErrInvalidPhone         = errors.New("invalid phone number")
+	ErrAccountFrozen        = errors.New("account is frozen")
+	ErrAccountClosed        = errors.New("account is closed")
+	ErrBalanceNotZero       = errors.New("account balance is not zero")
+	ErrInvalidKYCLevel      = errors.New("invalid kyc level")
+	ErrInvalidVerifier      = errors.New("invalid verifier")
+	ErrKYCDowngrade         = errors.New("kyc level can only be upgraded")
+	ErrInvalidPocketName    = errors.New("invalid pocket name")
+	ErrPocketNotFound       = errors.New("pocket not found")
+	ErrPaymentNotAuthorized = errors.New("payment is not authorized")
+	ErrCaptureExceedsHold   = errors.New("capture amount exceeds authorized amount")
+	ErrAccountHasHolds      = errors.New("account has active holds")
+	ErrIllegalTransition    = errors.New("illegal payment status transition")
+	ErrRefundNotAllowed     = errors.New("payment can not be refunded")
+	ErrRefundExceedsPayment = errors.New("refund exceeds payment amount")
+	ErrIdempotencyConflict  = errors.New("idempotency key reused with different parameters")
+	ErrScheduleNotFound     = errors.New("scheduled payment not found")
+	ErrScheduleInPast       = errors.New("scheduled payment must be in the future")
+	ErrScheduleNotPending   = errors.New("scheduled payment is not pending")
+	ErrInvalidRecurrence    = errors.New("invalid recurrence rule")
+	ErrInvalidFavoriteName  = errors.New("invalid favorite name")
+	ErrFavoriteNameTaken    = errors.New("favorite name already exists")
+	ErrInvalidAmountBounds  = errors.New("invalid favorite amount bounds")
+	ErrAmountOutOfBounds    = errors.New("amount is out of favorite bounds")
+
+	ErrNotEnoughPocketBalance    = errors.New("not enough pocket balance")
+	ErrInterestAlreadyCharged    = errors.New("overdraft interest already charged for this period")
+	ErrCardDailyLimitExceeded    = errors.New("card daily limit exceeded")
+	ErrCardCategoryLimitExceeded = errors.New("card category limit exceeded")
+	ErrKYCBalanceLimitExceeded   = errors.New("kyc tier balance limit exceeded")
+	ErrKYCPaymentLimitExceeded   = errors.New("kyc tier payment limit exceeded")
+	ErrKYCTurnoverLimitExceeded  = errors.New("kyc tier monthly turnover limit exceeded")
 )
 
 type Service struct {
@@ -35,24 +84,241 @@ type Service struct {
 	accounts      []*types.Account
 	payments      []*types.Payment
 	favorites     []*types.Favorite
+	nextCardID    int64
+	cards         []*types.Card
+	clock         func() time.Time
+	cardSpendings map[int64]*cardSpending
+	vault         *vault.Vault
+	claims        []*types.Claim
+
+	accountsByID       map[int64]*types.Account
+	accountsByPhone    map[types.Phone]*types.Account
+	paymentsByID       map[string]*types.Payment
+	paymentsByAccount  map[int64][]*types.Payment
+	favoritesByID      map[string]*types.Favorite
+	favoritesByAccount map[int64][]*types.Favorite
+	cardsByID          map[int64]*types.Card
+	cardsByAccount     map[int64][]*types.Card
+
+	tierPolicies map[types.KYCLevel]TierLimits
+	turnovers    map[int64]*accountTurnover
+
+	lastInterestCharge time.Time
+	transitions        map[string][]types.PaymentTransition
+	refundsByPayment   map[string][]*types.Payment
+
+	idempotencyKeys      map[string]*idempotencyRecord
+	idempotencyQueue     []*idempotencyRecord
+	idempotencyRetention time.Duration
+
+	schedules     []*types.ScheduledPayment
+	recurringRuns map[string][]types.RecurringRun
 }
 
-func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
-	for _, account := range s.accounts {
-		if account.Phone == phone {
-			return nil, ErrPhoneNumberRegistred
+func (s *Service) SetVault(v *vault.Vault) {
+	s.vault = v
+}
+
+func (s *Service) SetClock(clock func() time.Time) {
+	s.clock = clock
+}
+
+func (s *Service) now() time.Time {
+	if s.clock != nil {
+		return s.clock()
+	}
+
+	return time.Now()
+}
+
+func (s *Service) addAccount(account *types.Account) {
+	if s.accountsByID == nil {
+		s.accountsByID = make(map[int64]*types.Account)
+		s.accountsByPhone = make(map[types.Phone]*types.Account)
+	}
+
+	s.accounts = append(s.accounts, account)
+	s.accountsByID[account.ID] = account
+	s.accountsByPhone[account.Phone] = account
+}
+
+func (s *Service) setAccountPhone(account *types.Account, phone types.Phone) {
+	if s.accountsByPhone[account.Phone] == account {
+		delete(s.accountsByPhone, account.Phone)
+	}
+
+	account.Phone = phone
+	s.accountsByPhone[phone] = account
+}
+
+func (s *Service) addPayment(payments ...*types.Payment) {
+	if s.paymentsByID == nil {
+		s.paymentsByID = make(map[string]*types.Payment)
+		s.paymentsByAccount = make(map[int64][]*types.Payment)
+	}
+
+	for _, payment := range payments {
+		if payment.CreatedAt.IsZero() {
+			payment.CreatedAt = s.now()
+		}
+
+		if payment.UpdatedAt.IsZero() {
+			payment.UpdatedAt = payment.CreatedAt
+		}
+
+		s.payments = append(s.payments, payment)
+		s.paymentsByID[payment.ID] = payment
+		s.paymentsByAccount[payment.AccountID] = append(s.paymentsByAccount[payment.AccountID], payment)
+		s.recordTransition(payment.ID, "", payment.Status)
+
+		if payment.RefundOf != "" {
+			if s.refundsByPayment == nil {
+				s.refundsByPayment = make(map[string][]*types.Payment)
+			}
+			s.refundsByPayment[payment.RefundOf] = append(s.refundsByPayment[payment.RefundOf], payment)
+		}
+	}
+}
+
+func (s *Service) setPaymentAccount(payment *types.Payment, accountID int64) {
+	if payment.AccountID == accountID {
+		return
+	}
+
+	s.paymentsByAccount[payment.AccountID] = removePayment(s.paymentsByAccount[payment.AccountID], payment)
+	payment.AccountID = accountID
+	s.paymentsByAccount[accountID] = append(s.paymentsByAccount[accountID], payment)
+}
+
+func (s *Service) setPaymentRefundOf(payment *types.Payment, refundOf string) {
+	if payment.RefundOf == refundOf {
+		return
+	}
+
+	if payment.RefundOf != "" {
+		s.refundsByPayment[payment.RefundOf] = removePayment(s.refundsByPayment[payment.RefundOf], payment)
+		if len(s.refundsByPayment[payment.RefundOf]) == 0 {
+			delete(s.refundsByPayment, payment.RefundOf)
+		}
+	}
+
+	payment.RefundOf = refundOf
+
+	if refundOf != "" {
+		if s.refundsByPayment == nil {
+			s.refundsByPayment = make(map[string][]*types.Payment)
+		}
+		s.refundsByPayment[refundOf] = append(s.refundsByPayment[refundOf], payment)
+	}
+}
+
+func removePayment(payments []*types.Payment, target *types.Payment) []*types.Payment {
+	for i, payment := range payments {
+		if payment == target {
+			return append(payments[:i:i], payments[i+1:]...)
 		}
 	}
 
+	return payments
+}
+
+func (s *Service) addFavorite(favorite *types.Favorite) {
+	if s.favoritesByID == nil {
+		s.favoritesByID = make(map[string]*types.Favorite)
+		s.favoritesByAccount = make(map[int64][]*types.Favorite)
+	}
+
+	s.favorites = append(s.favorites, favorite)
+	s.favoritesByID[favorite.ID] = favorite
+	s.favoritesByAccount[favorite.AccountID] = append(s.favoritesByAccount[favorite.AccountID], favorite)
+}
+
+func (s *Service) setFavoriteAccount(favorite *types.Favorite, accountID int64) {
+	if favorite.AccountID == accountID {
+		return
+	}
+
+	s.favoritesByAccount[favorite.AccountID] = removeFavorite(s.favoritesByAccount[favorite.AccountID], favorite)
+	favorite.AccountID = accountID
+	s.favoritesByAccount[accountID] = append(s.favoritesByAccount[accountID], favorite)
+}
+
+func removeFavorite(favorites []*types.Favorite, target *types.Favorite) []*types.Favorite {
+	for i, favorite := range favorites {
+		if favorite == target {
+			return append(favorites[:i:i], favorites[i+1:]...)
+		}
+	}
+
+	return favorites
+}
+
+func (s *Service) addCard(card *types.Card) {
+	if s.cardsByID == nil {
+		s.cardsByID = make(map[int64]*types.Card)
+		s.cardsByAccount = make(map[int64][]*types.Card)
+	}
+
+	s.cards = append(s.cards, card)
+	s.cardsByID[card.ID] = card
+	s.cardsByAccount[card.AccountID] = append(s.cardsByAccount[card.AccountID], card)
+}
+
+func (s *Service) setCardAccount(card *types.Card, accountID int64) {
+	if card.AccountID == accountID {
+		return
+	}
+
+	cards := s.cardsByAccount[card.AccountID]
+	for i, item := range cards {
+		if item == card {
+			s.cardsByAccount[card.AccountID] = append(cards[:i:i], cards[i+1:]...)
+			break
+		}
+	}
+
+	card.AccountID = accountID
+	s.cardsByAccount[accountID] = append(s.cardsByAccount[accountID], card)
+}
+
+func (s *Service) RegisterAccount(phone types.Phone) (*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.FindAccountByPhone(phone)
+	if err == nil {
+		return nil, ErrPhoneNumberRegistred
+	}
+
 	s.nextAccountID++
 
 	account := &types.Account{
-		ID:      s.nextAccountID,
-		Phone:   phone,
-		Balance: 0,
+		ID:       s.nextAccountID,
+		Phone:    phone,
+		Balance:  0,
+		Status:   types.AccountStatusActive,
+		KYCLevel: types.KYCLevelAnonymous,
 	}
 
-	s.accounts = append(s.accounts, account)
+	s.addAccount(account)
+
+	s.payoutClaims(account)
+
+	return account, nil
+}
+
+func (s *Service) FindAccountByPhone(phone types.Phone) (*types.Account, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	account, ok := s.accountsByPhone[phone]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
 
 	return account, nil
 }
@@ -67,7 +333,140 @@ func (s *Service) Deposit(accountID int64, amount types.Money) error {
 		return err
 	}
 
+	err = checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	err = s.checkDepositLimits(account, amount)
+	if err != nil {
+		return err
+	}
+
 	account.Balance += amount
+	s.addTurnover(account.ID, amount)
+	return nil
+}
+
+func (s *Service) FreezeAccount(accountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	account.Status = types.AccountStatusFrozen
+
+	return nil
+}
+
+func (s *Service) UnfreezeAccount(accountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	account.Status = types.AccountStatusActive
+
+	return nil
+}
+
+func (s *Service) CloseAccount(accountID int64) error {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return err
+	}
+
+	if account.Status == types.AccountStatusClosed {
+		return ErrAccountClosed
+	}
+
+	if account.Held != 0 {
+		return ErrAccountHasHolds
+	}
+
+	if account.Balance != 0 || totalBalance(account) != 0 {
+		return ErrBalanceNotZero
+	}
+
+	account.Status = types.AccountStatusClosed
+
+	return nil
+}
+
+func (s *Service) CloseAccountWithPayout(accountID int64, cardID int64) (*types.Payment, error) {
+	account, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	if account.Held != 0 {
+		return nil, ErrAccountHasHolds
+	}
+
+	total := totalBalance(account)
+	if total < 0 {
+		return nil, ErrBalanceNotZero
+	}
+
+	if total > 0 {
+		card, err := s.FindCardByID(cardID)
+		if err != nil {
+			return nil, err
+		}
+
+		if card.AccountID != account.ID {
+			return nil, ErrCardNotLinked
+		}
+
+		err = s.checkCard(card)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	balance, pockets := account.Balance, account.Pockets
+	account.Pockets = copyPockets(pockets)
+	emptyPockets(account)
+
+	var payment *types.Payment
+	if account.Balance > 0 {
+		payment, err = s.transferToCard(account.ID, cardID, account.Balance, false)
+		if err != nil {
+			account.Balance, account.Pockets = balance, pockets
+			return nil, err
+		}
+	}
+
+	err = s.CloseAccount(account.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+func checkAccount(account *types.Account) error {
+	switch account.Status {
+	case types.AccountStatusFrozen:
+		return ErrAccountFrozen
+	case types.AccountStatusClosed:
+		return ErrAccountClosed
+	}
+
 	return nil
 }
 
@@ -81,11 +480,22 @@ func (s *Service) Pay(accountID int64, amount types.Money, category types.Paymen
 		return nil, err
 	}
 
-	if account.Balance < amount {
+	err = checkAccount(account)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkPaymentLimits(account, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if availableBalance(account) < amount {
 		return nil, ErrNotEnoughBalance
 
 	}
 	account.Balance -= amount
+	s.addTurnover(account.ID, amount)
 
 	paymentID := uuid.New().String()
 	payment := &types.Payment{
@@ -96,39 +506,359 @@ func (s *Service) Pay(accountID int64, amount types.Money, category types.Paymen
 		Status:    types.PaymentStatusInProgress,
 	}
 
-	s.payments = append(s.payments, payment)
-	return payment, nil
+	s.addPayment(payment)
+	return payment, nil
+
+}
+
+func (s *Service) Transfer(fromID int64, toID int64, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	if fromID == toID {
+		return nil, ErrSameAccount
+	}
+
+	from, err := s.FindAccountByID(fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.FindAccountByID(toID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(from)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(to)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkPaymentLimits(from, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.checkDepositLimits(to, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if availableBalance(from) < amount {
+		return nil, ErrNotEnoughBalance
+	}
+
+	from.Balance -= amount
+	to.Balance += amount
+	s.addTurnover(from.ID, amount)
+	s.addTurnover(to.ID, amount)
+
+	debit, _ := s.addTransferPayments(from.ID, to.ID, amount)
+
+	return debit, nil
+}
+
+func (s *Service) addTransferPayments(fromID int64, toID int64, amount types.Money) (*types.Payment, *types.Payment) {
+	debit := &types.Payment{
+		ID:          uuid.New().String(),
+		AccountID:   fromID,
+		Amount:      amount,
+		Category:    types.PaymentCategoryTransferOut,
+		Status:      types.PaymentStatusInProgress,
+		ToAccountID: toID,
+	}
+
+	credit := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: toID,
+		Amount:    amount,
+		Category:  types.PaymentCategoryTransferIn,
+		Status:    types.PaymentStatusInProgress,
+		LinkedID:  debit.ID,
+	}
+	debit.LinkedID = credit.ID
+
+	s.addPayment(debit, credit)
+
+	return debit, credit
+}
+
+func (s *Service) PayByPhone(fromID int64, phone types.Phone, amount types.Money) (*types.Payment, error) {
+	if amount <= 0 {
+		return nil, ErrAmountMustBePositive
+	}
+
+	from, err := s.FindAccountByID(fromID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkAccount(from)
+	if err != nil {
+		return nil, err
+	}
+
+	phone, err = NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	to, err := s.FindAccountByPhone(phone)
+	if err == nil {
+		return s.Transfer(from.ID, to.ID, amount)
+	}
+
+	err = s.checkPaymentLimits(from, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	if availableBalance(from) < amount {
+		return nil, ErrNotEnoughBalance
+	}
+
+	from.Balance -= amount
+	s.addTurnover(from.ID, amount)
+
+	payment := &types.Payment{
+		ID:        uuid.New().String(),
+		AccountID: from.ID,
+		Amount:    amount,
+		Category:  types.PaymentCategoryTransferOut,
+		Status:    types.PaymentStatusInProgress,
+	}
+
+	s.addPayment(payment)
+
+	claim := &types.Claim{
+		ID:            uuid.New().String(),
+		PaymentID:     payment.ID,
+		FromAccountID: from.ID,
+		Phone:         phone,
+		Amount:        amount,
+	}
+
+	s.claims = append(s.claims, claim)
+
+	return payment, nil
+}
+
+func (s *Service) FindClaimsByPhone(phone types.Phone) ([]types.Claim, error) {
+	phone, err := NormalizePhone(phone)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := []types.Claim{}
+	for _, claim := range s.claims {
+		if claim.Phone == phone {
+			claims = append(claims, *claim)
+		}
+	}
+
+	if len(claims) == 0 {
+		return nil, ErrClaimNotFound
+	}
+
+	return claims, nil
+}
+
+func (s *Service) retryClaims() {
+	accounts := []*types.Account{}
+	for _, claim := range s.claims {
+		account, ok := s.accountsByPhone[claim.Phone]
+		if ok {
+			accounts = append(accounts, account)
+		}
+	}
+
+	for _, account := range accounts {
+		s.payoutClaims(account)
+	}
+}
+
+func (s *Service) payoutClaims(account *types.Account) {
+	if checkAccount(account) != nil {
+		return
+	}
+
+	pending := []*types.Claim{}
+	for _, claim := range s.claims {
+		if claim.Phone != account.Phone {
+			pending = append(pending, claim)
+			continue
+		}
+
+		debit, err := s.FindPaymentByID(claim.PaymentID)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		err = s.checkDepositLimits(account, claim.Amount)
+		if err != nil {
+			log.Println(err)
+			pending = append(pending, claim)
+			continue
+		}
+
+		account.Balance += claim.Amount
+		s.addTurnover(account.ID, claim.Amount)
+
+		credit := &types.Payment{
+			ID:        uuid.New().String(),
+			AccountID: account.ID,
+			Amount:    claim.Amount,
+			Category:  types.PaymentCategoryTransferIn,
+			Status:    types.PaymentStatusInProgress,
+			LinkedID:  debit.ID,
+		}
+		debit.ToAccountID = account.ID
+		debit.LinkedID = credit.ID
+
+		s.addPayment(credit)
+	}
+
+	s.claims = pending
+}
+
+func (s *Service) removeClaimByPaymentID(paymentID string) {
+	for i, claim := range s.claims {
+		if claim.PaymentID == paymentID {
+			s.claims = append(s.claims[:i], s.claims[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *Service) FindAccountByID(accountID int64) (*types.Account, error) {
+	account, ok := s.accountsByID[accountID]
+	if !ok {
+		return nil, ErrAccountNotFound
+	}
+
+	return account, nil
+}
+
+func (s *Service) FindPaymentByID(paymentID string) (*types.Payment, error) {
+	payment, ok := s.paymentsByID[paymentID]
+	if !ok {
+		return nil, ErrPaymentNotFound
+	}
+
+	return payment, nil
+}
+
+func (s *Service) Reject(paymentID string) error {
+	targetPayment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+
+	if targetPayment.Category == types.PaymentCategoryTransferIn {
+		paymentID = targetPayment.LinkedID
+	}
+
+	targetPayment, targetAccount, err := s.findPaymentAndAccountByPaymentID(paymentID)
+	if err != nil {
+		return err
+	}
+
+	err = checkTransition(targetPayment, types.PaymentStatusFail)
+	if err != nil {
+		return err
+	}
+
+	var linkedPayment *types.Payment
+	if targetPayment.LinkedID != "" {
+		linkedPayment, err = s.FindPaymentByID(targetPayment.LinkedID)
+		if err != nil {
+			return err
+		}
+
+		err = checkTransition(linkedPayment, types.PaymentStatusFail)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = s.rollback(targetPayment, targetAccount)
+	if err != nil {
+		return err
+	}
+
+	s.setStatus(targetPayment, types.PaymentStatusFail)
+	if linkedPayment != nil {
+		s.setStatus(linkedPayment, types.PaymentStatusFail)
+	}
+
+	s.removeClaimByPaymentID(targetPayment.ID)
 
+	return nil
 }
 
-func (s *Service) FindAccountByID(accountID int64) (*types.Account, error) {
-	for _, account := range s.accounts {
-		if account.ID == accountID {
-			return account, nil
+func (s *Service) rollback(payment *types.Payment, account *types.Account) error {
+	if payment.Status == types.PaymentStatusAuthorized {
+		account.Held -= payment.Amount
+		return nil
+	}
+
+	amount := payment.Amount - s.refundedAmount(payment.ID)
+
+	var receiver *types.Account
+	if payment.ToAccountID != 0 {
+		to, err := s.FindAccountByID(payment.ToAccountID)
+		if err != nil {
+			return err
+		}
+
+		if to.Balance < amount {
+			return ErrNotEnoughBalance
 		}
+		receiver = to
 	}
 
-	return nil, ErrAccountNotFound
-}
+	var receiverCard *types.Card
+	if payment.ToCardID != 0 {
+		to, err := s.FindCardByID(payment.ToCardID)
+		if err != nil {
+			return err
+		}
 
-func (s *Service) FindPaymentByID(paymentID string) (*types.Payment, error) {
-	for _, payment := range s.payments {
-		if payment.ID == paymentID {
-			return payment, nil
+		if to.Balance < amount {
+			return ErrNotEnoughCardBalance
 		}
+		receiverCard = to
 	}
 
-	return nil, ErrPaymentNotFound
-}
+	var card *types.Card
+	if payment.CardID != 0 {
+		source, err := s.FindCardByID(payment.CardID)
+		if err != nil {
+			return err
+		}
+		card = source
+	}
 
-func (s *Service) Reject(paymentID string) error {
-	targetPayment, targetAccount, err := s.findPaymentAndAccountByPaymentID(paymentID)
-	if err != nil {
-		return err
+	if receiver != nil {
+		receiver.Balance -= amount
+	}
+
+	if receiverCard != nil {
+		receiverCard.Balance -= amount
 	}
 
-	targetPayment.Status = types.PaymentStatusFail
-	targetAccount.Balance += targetPayment.Amount
+	if card != nil {
+		card.Balance += amount
+	} else {
+		account.Balance += amount
+	}
 
 	return nil
 }
@@ -153,7 +883,7 @@ func (s *Service) Repeat(paymentID string) (*types.Payment, error) {
 		return nil, err
 	}
 
-	return s.Pay(targetAccount.ID, targetPayment.Amount, targetPayment.Category)
+	return s.PayWithDetails(targetAccount.ID, targetPayment.Amount, targetPayment.Category, targetPayment.Details)
 }
 
 func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorite, error) {
@@ -162,31 +892,27 @@ func (s *Service) FavoritePayment(paymentID string, name string) (*types.Favorit
 		return nil, err
 	}
 
+	name, err = s.checkFavoriteName(targetAccount.ID, name, nil)
+	if err != nil {
+		return nil, err
+	}
+
 	favorite := &types.Favorite{
 		ID:        uuid.New().String(),
 		AccountID: targetAccount.ID,
 		Name:      name,
 		Amount:    targetPayment.Amount,
 		Category:  targetPayment.Category,
+		Details:   cloneDetails(targetPayment.Details),
 	}
 
-	s.favorites = append(s.favorites, favorite)
+	s.addFavorite(favorite)
 
 	return favorite, nil
 }
 
 func (s *Service) PayFromFavorite(favoriteID string) (*types.Payment, error) {
-	favorite, err := s.FindFavoriteByID(favoriteID)
-	if err != nil {
-		return nil, err
-	}
-
-	payment, err := s.Pay(favorite.AccountID, favorite.Amount, favorite.Category)
-	if err != nil {
-		return nil, err
-	}
-
-	return payment, nil
+	return s.PayFromFavoriteWith(favoriteID, types.FavoriteParams{})
 }
 
 func (s *Service) ExportToFile(path string) error {
@@ -231,13 +957,37 @@ func (s *Service) ImportFromFile(path string) error {
 				return err
 			}
 
-			newAccount := &types.Account{
-				ID:      int64(id),
-				Phone:   types.Phone(datas[1]),
-				Balance: types.Money(balance),
+			phone, err := NormalizePhone(types.Phone(datas[1]))
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+
+			owner, err := s.FindAccountByPhone(phone)
+			if err == nil && owner.ID != int64(id) {
+				log.Println(ErrPhoneNumberRegistred)
+				continue
+			}
+
+			account, err := s.FindAccountByID(int64(id))
+			if err != nil {
+				account = &types.Account{
+					ID:       int64(id),
+					Phone:    phone,
+					Status:   types.AccountStatusActive,
+					KYCLevel: types.KYCLevelAnonymous,
+				}
+
+				s.addAccount(account)
+			} else {
+				s.setAccountPhone(account, phone)
 			}
 
-			s.accounts = append(s.accounts, newAccount)
+			account.Balance = types.Money(balance)
+
+			if account.ID > s.nextAccountID {
+				s.nextAccountID = account.ID
+			}
 		}
 	}
 
@@ -250,7 +1000,14 @@ func (s *Service) Export(dir string) error {
 		for _, account := range s.accounts {
 			result += strconv.Itoa(int(account.ID)) + ";"
 			result += string(account.Phone) + ";"
-			result += strconv.Itoa(int(account.Balance)) + "\n"
+			result += strconv.Itoa(int(account.Balance)) + ";"
+			result += string(account.Status) + ";"
+			result += string(account.KYCLevel) + ";"
+			result += account.VerifiedBy + ";"
+			result += strconv.Itoa(int(account.CreditLimit)) + ";"
+			result += strconv.FormatInt(account.OverdraftRate, 10) + ";"
+			result += pocketsToString(account.Pockets) + ";"
+			result += strconv.Itoa(int(account.Held)) + "\n"
 		}
 
 		err := actionByFile(dir+"/accounts.dump", result)
@@ -262,11 +1019,7 @@ func (s *Service) Export(dir string) error {
 	if s.payments != nil {
 		result := ""
 		for _, payment := range s.payments {
-			result += payment.ID + ";"
-			result += strconv.Itoa(int(payment.AccountID)) + ";"
-			result += strconv.Itoa(int(payment.Amount)) + ";"
-			result += string(payment.Category) + ";"
-			result += string(payment.Status) + "\n"
+			result += paymentToString(*payment)
 		}
 
 		err := actionByFile(dir+"/payments.dump", result)
@@ -282,7 +1035,10 @@ func (s *Service) Export(dir string) error {
 			result += strconv.Itoa(int(favorite.AccountID)) + ";"
 			result += favorite.Name + ";"
 			result += strconv.Itoa(int(favorite.Amount)) + ";"
-			result += string(favorite.Category) + "\n"
+			result += string(favorite.Category) + ";"
+			result += detailsToString(favorite.Details) + ";"
+			result += recurrenceToString(favorite.Recurrence) + ";"
+			result += templateToString(favorite.Template) + "\n"
 		}
 
 		err := actionByFile(dir+"/favorites.dump", result)
@@ -291,6 +1047,58 @@ func (s *Service) Export(dir string) error {
 		}
 	}
 
+	if s.cards != nil {
+		result := ""
+		for _, card := range s.cards {
+			result += strconv.Itoa(int(card.ID)) + ";"
+			result += strconv.Itoa(int(card.AccountID)) + ";"
+			result += card.Issuer + ";"
+			result += strconv.Itoa(int(card.Balance)) + ";"
+			result += card.Currency + ";"
+			result += card.Number + ";"
+			result += card.Token + ";"
+			result += string(card.Status) + ";"
+			result += strconv.FormatInt(unixOrZero(card.ExpiresAt), 10) + ";"
+			result += strconv.Itoa(int(card.DailyLimit)) + ";"
+			result += categoryLimitsToString(card.CategoryLimits) + "\n"
+		}
+
+		err := actionByFile(dir+"/cards.dump", result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.claims != nil {
+		result := ""
+		for _, claim := range s.claims {
+			result += claim.ID + ";"
+			result += claim.PaymentID + ";"
+			result += strconv.Itoa(int(claim.FromAccountID)) + ";"
+			result += string(claim.Phone) + ";"
+			result += strconv.Itoa(int(claim.Amount)) + "\n"
+		}
+
+		err := actionByFile(dir+"/claims.dump", result)
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.schedules != nil {
+		err := actionByFile(dir+"/schedules.dump", schedulesToString(s.schedules))
+		if err != nil {
+			return err
+		}
+	}
+
+	if s.vault != nil {
+		err := s.vault.Export(dir + "/vault.dump")
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -313,6 +1121,32 @@ func (s *Service) Import(dir string) error {
 		return err
 	}
 
+	err = s.actionByClaims(dir + "/claims.dump")
+	if err != nil {
+		log.Println("err from actionByClaims")
+		return err
+	}
+
+	err = s.actionBySchedules(dir + "/schedules.dump")
+	if err != nil {
+		log.Println("err from actionBySchedules")
+		return err
+	}
+
+	if s.vault != nil {
+		err = s.vault.Import(dir + "/vault.dump")
+		if err != nil && !os.IsNotExist(err) {
+			log.Println("err from vault import")
+			return err
+		}
+	}
+
+	err = s.actionByCards(dir + "/cards.dump")
+	if err != nil {
+		log.Println("err from actionByCards")
+		return err
+	}
+
 	return nil
 }
 
@@ -335,7 +1169,11 @@ func (s *Service) actionByAccounts(path string) error {
 				return err
 			}
 
-			phone := types.Phone(data[1])
+			phone, err := NormalizePhone(types.Phone(data[1]))
+			if err != nil {
+				log.Println(err)
+				continue
+			}
 
 			balance, err := strconv.Atoi(data[2])
 			if err != nil {
@@ -343,19 +1181,71 @@ func (s *Service) actionByAccounts(path string) error {
 				return err
 			}
 
+			status := types.AccountStatusActive
+			if len(data) > 3 {
+				status = types.AccountStatus(data[3])
+			}
+
+			kycLevel := types.KYCLevelAnonymous
+			verifiedBy := ""
+			if len(data) > 5 {
+				kycLevel = types.KYCLevel(data[4])
+				verifiedBy = data[5]
+			}
+
+			creditLimit := 0
+			var overdraftRate int64
+			if len(data) > 7 {
+				creditLimit, err = strconv.Atoi(data[6])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+
+				overdraftRate, err = strconv.ParseInt(data[7], 10, 64)
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
+			var pockets map[string]types.Money
+			if len(data) > 8 {
+				pockets, err = parsePockets(data[8])
+				if err != nil {
+					log.Println("can't parse pockets")
+					return err
+				}
+			}
+
+			held := 0
+			if len(data) > 9 {
+				held, err = strconv.Atoi(data[9])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
 			account, err := s.FindAccountByID(int64(id))
 			if err != nil {
-				acc, err := s.RegisterAccount(phone)
+				account, err = s.RegisterAccount(phone)
 				if err != nil {
 					log.Println("err from register account")
 					return err
 				}
-
-				acc.Balance = types.Money(balance)
 			} else {
-				account.Phone = phone
-				account.Balance = types.Money(balance)
+				s.setAccountPhone(account, phone)
 			}
+
+			account.Balance = types.Money(balance)
+			account.Status = status
+			account.KYCLevel = kycLevel
+			account.VerifiedBy = verifiedBy
+			account.CreditLimit = types.Money(creditLimit)
+			account.OverdraftRate = overdraftRate
+			account.Pockets = pockets
+			account.Held = types.Money(held)
 		}
 	} else {
 		log.Println(ErrFileNotFound.Error())
@@ -394,22 +1284,108 @@ func (s *Service) actionByPayments(path string) error {
 
 			status := types.PaymentStatus(data[4])
 
+			cardID := 0
+			toAccountID := 0
+			if len(data) > 6 {
+				cardID, err = strconv.Atoi(data[5])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+
+				toAccountID, err = strconv.Atoi(data[6])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
+			toCardID := 0
+			if len(data) > 7 {
+				toCardID, err = strconv.Atoi(data[7])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
+			linkedID := ""
+			if len(data) > 8 {
+				linkedID = data[8]
+			}
+
+			refundOf := ""
+			if len(data) > 9 {
+				refundOf = data[9]
+			}
+
+			var createdAt, updatedAt int64
+			if len(data) > 11 {
+				createdAt, err = strconv.ParseInt(data[10], 10, 64)
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+
+				updatedAt, err = strconv.ParseInt(data[11], 10, 64)
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
+			details := types.PaymentDetails{}
+			if len(data) > 12 {
+				details, err = parseDetails(data[12])
+				if err != nil {
+					log.Println(err)
+					return err
+				}
+			}
+
+			authorized := 0
+			if len(data) > 13 {
+				authorized, err = strconv.Atoi(data[13])
+				if err != nil {
+					log.Println("can't parse str to int")
+					return err
+				}
+			}
+
 			payment, err := s.FindPaymentByID(id)
 			if err != nil {
 				newPayment := &types.Payment{
-					ID:        id,
-					AccountID: int64(accountID),
-					Amount:    types.Money(amount),
-					Category:  types.PaymentCategory(category),
-					Status:    types.PaymentStatus(status),
+					ID:          id,
+					AccountID:   int64(accountID),
+					Amount:      types.Money(amount),
+					Category:    types.PaymentCategory(category),
+					Status:      types.PaymentStatus(status),
+					CardID:      int64(cardID),
+					ToAccountID: int64(toAccountID),
+					ToCardID:    int64(toCardID),
+					LinkedID:    linkedID,
+					RefundOf:    refundOf,
+					CreatedAt:   timeFromUnixNano(createdAt),
+					UpdatedAt:   timeFromUnixNano(updatedAt),
+					Details:     details,
+					Authorized:  types.Money(authorized),
 				}
 
-				s.payments = append(s.payments, newPayment)
+				s.addPayment(newPayment)
 			} else {
-				payment.AccountID = int64(accountID)
+				s.setPaymentAccount(payment, int64(accountID))
 				payment.Amount = types.Money(amount)
 				payment.Category = category
 				payment.Status = status
+				payment.CardID = int64(cardID)
+				payment.ToAccountID = int64(toAccountID)
+				payment.ToCardID = int64(toCardID)
+				payment.LinkedID = linkedID
+				s.setPaymentRefundOf(payment, refundOf)
+				payment.CreatedAt = timeFromUnixNano(createdAt)
+				payment.UpdatedAt = timeFromUnixNano(updatedAt)
+				payment.Details = details
+				payment.Authorized = types.Money(authorized)
 			}
 		}
 	} else {
@@ -449,22 +1425,55 @@ func (s *Service) actionByFavorites(path string) error {
 
 			category := types.PaymentCategory(data[4])
 
+			details := types.PaymentDetails{}
+			if len(data) > 5 {
+				details, err = parseDetails(data[5])
+				if err != nil {
+					log.Println(err)
+					return err
+				}
+			}
+
+			var recurrence *types.Recurrence
+			if len(data) > 6 {
+				recurrence, err = parseRecurrence(data[6])
+				if err != nil {
+					log.Println(err)
+					return err
+				}
+			}
+
+			var template *types.FavoriteTemplate
+			if len(data) > 7 {
+				template, err = parseTemplate(data[7])
+				if err != nil {
+					log.Println(err)
+					return err
+				}
+			}
+
 			favorite, err := s.FindFavoriteByID(id)
 			if err != nil {
 				newFavorite := &types.Favorite{
-					ID:        id,
-					AccountID: int64(accountID),
-					Name:      name,
-					Amount:    types.Money(amount),
-					Category:  types.PaymentCategory(category),
+					ID:         id,
+					AccountID:  int64(accountID),
+					Name:       name,
+					Amount:     types.Money(amount),
+					Category:   types.PaymentCategory(category),
+					Details:    details,
+					Recurrence: recurrence,
+					Template:   template,
 				}
 
-				s.favorites = append(s.favorites, newFavorite)
+				s.addFavorite(newFavorite)
 			} else {
-				favorite.AccountID = int64(accountID)
+				s.setFavoriteAccount(favorite, int64(accountID))
 				favorite.Name = name
 				favorite.Amount = types.Money(amount)
 				favorite.Category = category
+				favorite.Details = details
+				favorite.Recurrence = recurrence
+				favorite.Template = template
 			}
 		}
 	} else {
@@ -474,14 +1483,98 @@ func (s *Service) actionByFavorites(path string) error {
 	return nil
 }
 
-func (s *Service) FindFavoriteByID(id string) (*types.Favorite, error) {
-	for _, favorite := range s.favorites {
-		if favorite.ID == id {
-			return favorite, nil
+func (s *Service) actionByClaims(path string) error {
+	byteData, err := ioutil.ReadFile(path)
+	if err == nil {
+		datas := string(byteData)
+		splits := strings.Split(datas, "\n")
+
+		for _, split := range splits {
+			if len(split) == 0 {
+				break
+			}
+
+			data := strings.Split(split, ";")
+			id := data[0]
+			paymentID := data[1]
+
+			fromAccountID, err := strconv.Atoi(data[2])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			phone := types.Phone(data[3])
+
+			amount, err := strconv.Atoi(data[4])
+			if err != nil {
+				log.Println("can't parse str to int")
+				return err
+			}
+
+			exists := false
+			for _, claim := range s.claims {
+				if claim.ID == id {
+					exists = true
+				}
+			}
+
+			if !exists {
+				s.claims = append(s.claims, &types.Claim{
+					ID:            id,
+					PaymentID:     paymentID,
+					FromAccountID: int64(fromAccountID),
+					Phone:         phone,
+					Amount:        types.Money(amount),
+				})
+			}
 		}
+	} else {
+		log.Println(ErrFileNotFound.Error())
+	}
+
+	return nil
+}
+
+func (s *Service) FindFavoriteByID(id string) (*types.Favorite, error) {
+	favorite, ok := s.favoritesByID[id]
+	if !ok {
+		return nil, ErrFavoriteNotFound
+	}
+
+	return favorite, nil
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
 	}
 
-	return nil, ErrFavoriteNotFound
+	return t.Unix()
+}
+
+func timeOrZero(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(unix, 0)
+}
+
+func unixNanoOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+
+	return t.UnixNano()
+}
+
+func timeFromUnixNano(unix int64) time.Time {
+	if unix == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(0, unix)
 }
 
 func actionByFile(path, data string) error {
@@ -514,10 +1607,8 @@ func (s *Service) ExportAccountHistory(accountID int64) (payments []types.Paymen
 		return nil, err
 	}
 
-	for _, payment := range s.payments {
-		if payment.AccountID == accountID {
-			payments = append(payments, *payment)
-		}
+	for _, payment := range s.paymentsByAccount[accountID] {
+		payments = append(payments, *payment)
 	}
 
 	if len(payments) == 0 {
@@ -528,6 +1619,31 @@ func (s *Service) ExportAccountHistory(accountID int64) (payments []types.Paymen
 	return payments, nil
 }
 
+func (s *Service) AccountHistoryBetween(accountID int64, from time.Time, to time.Time) ([]types.Payment, error) {
+	_, err := s.FindAccountByID(accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	payments := []types.Payment{}
+	for _, payment := range s.paymentsByAccount[accountID] {
+		if payment.CreatedAt.Before(from) || !payment.CreatedAt.Before(to) {
+			continue
+		}
+		payments = append(payments, *payment)
+	}
+
+	if len(payments) == 0 {
+		return nil, ErrPaymentNotFound
+	}
+
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].CreatedAt.Before(payments[j].CreatedAt)
+	})
+
+	return payments, nil
+}
+
 func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records int) error {
 	if len(payments) == 0 {
 		log.Print(ErrPaymentNotFound)
@@ -539,11 +1655,7 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 	if len(payments) <= records {
 		result := ""
 		for _, payment := range payments {
-			result += payment.ID + ";"
-			result += strconv.Itoa(int(payment.AccountID)) + ";"
-			result += strconv.Itoa(int(payment.Amount)) + ";"
-			result += string(payment.Category) + ";"
-			result += string(payment.Status) + "\n"
+			result += paymentToString(payment)
 		}
 
 		err := actionByFile(dir+"/payments.dump", result)
@@ -557,11 +1669,7 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 	result := ""
 	k := 1
 	for i, payment := range payments {
-		result += payment.ID + ";"
-		result += strconv.Itoa(int(payment.AccountID)) + ";"
-		result += strconv.Itoa(int(payment.Amount)) + ";"
-		result += string(payment.Category) + ";"
-		result += string(payment.Status) + "\n"
+		result += paymentToString(payment)
 
 		if (i+1)%records == 0 {
 			err := actionByFile(dir+"/payments"+strconv.Itoa(k)+".dump", result)
@@ -583,6 +1691,25 @@ func (s *Service) HistoryToFiles(payments []types.Payment, dir string, records i
 	return nil
 }
 
+func paymentToString(payment types.Payment) string {
+	result := payment.ID + ";"
+	result += strconv.Itoa(int(payment.AccountID)) + ";"
+	result += strconv.Itoa(int(payment.Amount)) + ";"
+	result += string(payment.Category) + ";"
+	result += string(payment.Status) + ";"
+	result += strconv.Itoa(int(payment.CardID)) + ";"
+	result += strconv.Itoa(int(payment.ToAccountID)) + ";"
+	result += strconv.Itoa(int(payment.ToCardID)) + ";"
+	result += payment.LinkedID + ";"
+	result += payment.RefundOf + ";"
+	result += strconv.FormatInt(unixNanoOrZero(payment.CreatedAt), 10) + ";"
+	result += strconv.FormatInt(unixNanoOrZero(payment.UpdatedAt), 10) + ";"
+	result += detailsToString(payment.Details) + ";"
+	result += strconv.Itoa(int(payment.Authorized)) + "\n"
+
+	return result
+}
+
 func (s *Service) SumPayments(goroutines int) types.Money {
 	wg := sync.WaitGroup{}
 	mu := sync.Mutex{}
@@ -634,13 +1761,7 @@ func (s *Service) FilterPayments(accountID int64, goroutines int) ([]types.Payme
 			defer wg.Done()
 			for _, payment := range payments {
 				if payment.AccountID == accountID {
-					filteredPayments = append(filteredPayments, types.Payment{
-						ID:        payment.ID,
-						AccountID: payment.AccountID,
-						Amount:    payment.Amount,
-						Category:  payment.Category,
-						Status:    payment.Status,
-					})
+					filteredPayments = append(filteredPayments, *payment)
 				}
 			}
 		}(s.payments)
@@ -659,13 +1780,7 @@ func (s *Service) FilterPayments(accountID int64, goroutines int) ([]types.Payme
 				separetePayments := []types.Payment{}
 				for _, payment := range payments {
 					if payment.AccountID == accountID {
-						separetePayments = append(separetePayments, types.Payment{
-							ID:        payment.ID,
-							AccountID: payment.AccountID,
-							Amount:    payment.Amount,
-							Category:  payment.Category,
-							Status:    payment.Status,
-						})
+						separetePayments = append(separetePayments, *payment)
 					}
 				}
 				mu.Lock()
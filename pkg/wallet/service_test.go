@@ -5,7 +5,9 @@ import (
 	"io/ioutil"
 	"os"
 	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/shuhrat-shokirov/wallet/pkg/types"
 )
@@ -669,4 +671,778 @@ func TestService_SumPaymentsWithProgress(t *testing.T) {
 
 	svc.SumPaymentsWithProgress()
 	
-}
\ No newline at end of file
+}
+
+func TestService_Transfer_success(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(from.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	debit, err := svc.Transfer(from.ID, to.ID, 400)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 600 || to.Balance != 400 {
+		t.Errorf("invalid balances, from %v, to %v", from.Balance, to.Balance)
+	}
+
+	credit, err := svc.FindPaymentByID(debit.LinkedID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if credit.AccountID != to.ID || credit.LinkedID != debit.ID || credit.Category != types.PaymentCategoryTransferIn {
+		t.Errorf("invalid credit payment, got %v", credit)
+	}
+
+	err = svc.Reject(credit.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 1000 || to.Balance != 0 {
+		t.Errorf("invalid balances after reject, from %v, to %v", from.Balance, to.Balance)
+	}
+
+	if debit.Status != types.PaymentStatusFail || credit.Status != types.PaymentStatusFail {
+		t.Errorf("invalid statuses, debit %v, credit %v", debit.Status, credit.Status)
+	}
+}
+
+func TestService_Transfer_fail(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Transfer(from.ID, to.ID, 0)
+	if err != ErrAmountMustBePositive {
+		t.Error(err)
+	}
+
+	_, err = svc.Transfer(from.ID, from.ID, 10)
+	if err != ErrSameAccount {
+		t.Error(err)
+	}
+
+	_, err = svc.Transfer(from.ID, 3, 10)
+	if err != ErrAccountNotFound {
+		t.Error(err)
+	}
+
+	_, err = svc.Transfer(from.ID, to.ID, 10)
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	from.Balance = 100
+
+	debit, err := svc.Transfer(from.ID, to.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to.Balance = 50
+
+	err = svc.Reject(debit.ID)
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	if from.Balance != 0 || to.Balance != 50 || debit.Status == types.PaymentStatusFail {
+		t.Errorf("reject must not change state, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_PayByPhone_registered(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	payment, err := svc.PayByPhone(from.ID, to.Phone, 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.ToAccountID != to.ID || from.Balance != 700 || to.Balance != 300 {
+		t.Errorf("invalid transfer, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_PayByPhone_pendingClaim(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	_, err = svc.PayByPhone(from.ID, "+992000000001", 2000)
+	if err != ErrNotEnoughBalance {
+		t.Error(err)
+	}
+
+	payment, err := svc.PayByPhone(from.ID, "+992000000001", 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 700 {
+		t.Errorf("invalid balance, got %v", from.Balance)
+	}
+
+	claims, err := svc.FindClaimsByPhone("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(claims) != 1 || claims[0].PaymentID != payment.ID {
+		t.Errorf("invalid claims, got %v", claims)
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if to.Balance != 300 || payment.ToAccountID != to.ID {
+		t.Errorf("claim must be paid out, balance %v", to.Balance)
+	}
+
+	_, err = svc.FindClaimsByPhone("+992000000001")
+	if err != ErrClaimNotFound {
+		t.Error(err)
+	}
+
+	err = svc.Reject(payment.LinkedID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 1000 || to.Balance != 0 {
+		t.Errorf("invalid balances after reject, from %v, to %v", from.Balance, to.Balance)
+	}
+}
+
+func TestService_PayByPhone_rejectPendingClaim(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	payment, err := svc.PayByPhone(from.ID, "+992000000001", 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if from.Balance != 1000 {
+		t.Errorf("invalid balance, got %v", from.Balance)
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if to.Balance != 0 {
+		t.Errorf("rejected claim must not be paid out, got %v", to.Balance)
+	}
+}
+
+func TestService_AccountStatus(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Status != types.AccountStatusActive {
+		t.Errorf("invalid status, got %v", account.Status)
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.FreezeAccount(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 100)
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, 100, "auto")
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	_, err = svc.Repeat(payment.ID)
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	_, err = svc.PayFromFavorite(favorite.ID)
+	if err != ErrAccountFrozen {
+		t.Error(err)
+	}
+
+	err = svc.UnfreezeAccount(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = svc.Repeat(payment.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = svc.CloseAccount(account.ID)
+	if err != ErrBalanceNotZero {
+		t.Error(err)
+	}
+
+	_, err = svc.Pay(account.ID, account.Balance, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.CloseAccount(account.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 100)
+	if err != ErrAccountClosed {
+		t.Error(err)
+	}
+
+	err = svc.UnfreezeAccount(account.ID)
+	if err != ErrAccountClosed {
+		t.Error(err)
+	}
+}
+
+func TestService_Indexes_import(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	favorite, err := svc.FavoritePayment(payment.ID, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	gotAccount, err := restored.FindAccountByPhone("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	gotPayment, err := restored.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = restored.FindFavoriteByID(favorite.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	history, err := restored.ExportAccountHistory(gotAccount.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(history) != 1 || history[0].ID != gotPayment.ID {
+		t.Errorf("invalid history, got %v", history)
+	}
+
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	history, err = restored.ExportAccountHistory(gotAccount.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(history) != 1 {
+		t.Errorf("repeated import must not duplicate history, got %v", history)
+	}
+}
+
+func TestService_ImportFromFile_indexes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	path := dir + "/accounts.txt"
+	err = ioutil.WriteFile(path, []byte("1;+992000000000;100|2;+992000000001;200|"), 0666)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	svc := &Service{}
+	for i := 0; i < 2; i++ {
+		err = svc.ImportFromFile(path)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	if len(svc.accounts) != 2 {
+		t.Errorf("repeated import must not duplicate accounts, got %v", len(svc.accounts))
+	}
+
+	account, err := svc.FindAccountByID(1)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if account.Status != types.AccountStatusActive || account.KYCLevel != types.KYCLevelAnonymous {
+		t.Errorf("invalid defaults, got %v, %v", account.Status, account.KYCLevel)
+	}
+
+	registered, err := svc.RegisterAccount("+992000000002")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if registered.ID != 3 {
+		t.Errorf("new account must not reuse imported id, got %v", registered.ID)
+	}
+
+	got, err := svc.FindAccountByID(1)
+	if err != nil || got != account {
+		t.Errorf("imported account must stay indexed, got %v, %v", got, err)
+	}
+}
+
+func newBenchmarkService(b *testing.B, count int) (*Service, []string) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	ids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		payment := &types.Payment{
+			ID:        uuid.New().String(),
+			AccountID: account.ID,
+			Amount:    1,
+			Status:    types.PaymentStatusInProgress,
+		}
+		svc.addPayment(payment)
+		ids = append(ids, payment.ID)
+	}
+
+	return svc, ids
+}
+
+func scanPaymentByID(payments []*types.Payment, paymentID string) (*types.Payment, error) {
+	for _, payment := range payments {
+		if payment.ID == paymentID {
+			return payment, nil
+		}
+	}
+
+	return nil, ErrPaymentNotFound
+}
+
+func Benchmark_FindPaymentByID_index(b *testing.B) {
+	svc, ids := newBenchmarkService(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := svc.FindPaymentByID(ids[i%len(ids)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_FindPaymentByID_scan(b *testing.B) {
+	svc, ids := newBenchmarkService(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := scanPaymentByID(svc.payments, ids[i%len(ids)])
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func newBenchmarkAccounts(b *testing.B, count int) *Service {
+	svc := &Service{}
+
+	for i := 0; i < count; i++ {
+		account, err := svc.RegisterAccount(types.Phone("+992" + strconv.Itoa(100_000_000+i)))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		favorite := &types.Favorite{
+			ID:        uuid.New().String(),
+			AccountID: account.ID,
+			Name:      "auto",
+			Amount:    1,
+		}
+		svc.addFavorite(favorite)
+	}
+
+	return svc
+}
+
+func scanAccountByID(accounts []*types.Account, accountID int64) (*types.Account, error) {
+	for _, account := range accounts {
+		if account.ID == accountID {
+			return account, nil
+		}
+	}
+
+	return nil, ErrAccountNotFound
+}
+
+func scanAccountByPhone(accounts []*types.Account, phone types.Phone) (*types.Account, error) {
+	for _, account := range accounts {
+		if account.Phone == phone {
+			return account, nil
+		}
+	}
+
+	return nil, ErrAccountNotFound
+}
+
+func scanFavoriteByID(favorites []*types.Favorite, favoriteID string) (*types.Favorite, error) {
+	for _, favorite := range favorites {
+		if favorite.ID == favoriteID {
+			return favorite, nil
+		}
+	}
+
+	return nil, ErrFavoriteNotFound
+}
+
+func Benchmark_FindAccountByID_index(b *testing.B) {
+	svc := newBenchmarkAccounts(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := svc.FindAccountByID(svc.accounts[i%len(svc.accounts)].ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_FindAccountByID_scan(b *testing.B) {
+	svc := newBenchmarkAccounts(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := scanAccountByID(svc.accounts, svc.accounts[i%len(svc.accounts)].ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_FindAccountByPhone_index(b *testing.B) {
+	svc := newBenchmarkAccounts(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := svc.FindAccountByPhone(svc.accounts[i%len(svc.accounts)].Phone)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_FindAccountByPhone_scan(b *testing.B) {
+	svc := newBenchmarkAccounts(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := scanAccountByPhone(svc.accounts, svc.accounts[i%len(svc.accounts)].Phone)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_FindFavoriteByID_index(b *testing.B) {
+	svc := newBenchmarkAccounts(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := svc.FindFavoriteByID(svc.favorites[i%len(svc.favorites)].ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_FindFavoriteByID_scan(b *testing.B) {
+	svc := newBenchmarkAccounts(b, 100_000)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, err := scanFavoriteByID(svc.favorites, svc.favorites[i%len(svc.favorites)].ID)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestService_AccountHistoryBetween(t *testing.T) {
+	now := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	ids := []string{}
+	for i := 0; i < 3; i++ {
+		payment, err := svc.Pay(account.ID, 100, "auto")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ids = append(ids, payment.ID)
+		now = now.Add(time.Hour)
+	}
+
+	from := time.Date(2020, 11, 15, 13, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 11, 15, 15, 0, 0, 0, time.UTC)
+
+	payments, err := svc.AccountHistoryBetween(account.ID, from, to)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if len(payments) != 2 || payments[0].ID != ids[1] || payments[1].ID != ids[2] {
+		t.Errorf("invalid history, got %v", payments)
+	}
+
+	_, err = svc.AccountHistoryBetween(account.ID, to, to.Add(time.Hour))
+	if err != ErrPaymentNotFound {
+		t.Error(err)
+	}
+
+	_, err = svc.AccountHistoryBetween(account.ID+1, from, to)
+	if err != ErrAccountNotFound {
+		t.Error(err)
+	}
+}
+
+func TestService_PaymentTimestamps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallet")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	created := time.Date(2020, 11, 15, 12, 0, 0, 0, time.UTC)
+	now := created
+
+	svc := &Service{}
+	svc.SetClock(func() time.Time {
+		return now
+	})
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	account.Balance = 1000
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !payment.CreatedAt.Equal(created) || !payment.UpdatedAt.Equal(created) {
+		t.Errorf("invalid timestamps, got %v, %v", payment.CreatedAt, payment.UpdatedAt)
+	}
+
+	now = now.Add(time.Minute)
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !payment.CreatedAt.Equal(created) || !payment.UpdatedAt.Equal(now) {
+		t.Errorf("invalid timestamps after reject, got %v, %v", payment.CreatedAt, payment.UpdatedAt)
+	}
+
+	err = svc.Export(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	restored := &Service{}
+	err = restored.Import(dir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	got, err := restored.FindPaymentByID(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !got.CreatedAt.Equal(created) || !got.UpdatedAt.Equal(now) {
+		t.Errorf("timestamps must survive import, got %v, %v", got.CreatedAt, got.UpdatedAt)
+	}
+}
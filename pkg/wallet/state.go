@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+var paymentTransitions = map[types.PaymentStatus][]types.PaymentStatus{
+	types.PaymentStatusInProgress: {types.PaymentStatusOk, types.PaymentStatusFail, types.PaymentStatusRefunded},
+	types.PaymentStatusAuthorized: {types.PaymentStatusOk, types.PaymentStatusVoided, types.PaymentStatusFail},
+	types.PaymentStatusOk:         {types.PaymentStatusRefunded},
+}
+
+type TransitionError struct {
+	PaymentID string
+	From      types.PaymentStatus
+	To        types.PaymentStatus
+}
+
+func (e *TransitionError) Error() string {
+	return "payment " + e.PaymentID + ": illegal status transition " + string(e.From) + " -> " + string(e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrIllegalTransition
+}
+
+func (s *Service) Complete(paymentID string) error {
+	payment, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return err
+	}
+
+	err = checkTransition(payment, types.PaymentStatusOk)
+	if err != nil {
+		return err
+	}
+
+	var linkedPayment *types.Payment
+	if payment.LinkedID != "" {
+		linkedPayment, err = s.FindPaymentByID(payment.LinkedID)
+		if err != nil {
+			return err
+		}
+
+		err = checkTransition(linkedPayment, types.PaymentStatusOk)
+		if err != nil {
+			return err
+		}
+	}
+
+	if payment.Status == types.PaymentStatusAuthorized {
+		_, err = s.Capture(payment.ID, 0)
+		return err
+	}
+
+	s.setStatus(payment, types.PaymentStatusOk)
+	if linkedPayment != nil {
+		s.setStatus(linkedPayment, types.PaymentStatusOk)
+	}
+
+	return nil
+}
+
+func (s *Service) PaymentHistory(paymentID string) ([]types.PaymentTransition, error) {
+	_, err := s.FindPaymentByID(paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]types.PaymentTransition, len(s.transitions[paymentID]))
+	copy(history, s.transitions[paymentID])
+
+	return history, nil
+}
+
+func checkTransition(payment *types.Payment, to types.PaymentStatus) error {
+	for _, status := range paymentTransitions[payment.Status] {
+		if status == to {
+			return nil
+		}
+	}
+
+	return &TransitionError{
+		PaymentID: payment.ID,
+		From:      payment.Status,
+		To:        to,
+	}
+}
+
+func (s *Service) setStatus(payment *types.Payment, to types.PaymentStatus) {
+	s.recordTransition(payment.ID, payment.Status, to)
+	payment.Status = to
+	payment.UpdatedAt = s.now()
+}
+
+func (s *Service) recordTransition(paymentID string, from types.PaymentStatus, to types.PaymentStatus) {
+	if s.transitions == nil {
+		s.transitions = make(map[string][]types.PaymentTransition)
+	}
+
+	s.transitions[paymentID] = append(s.transitions[paymentID], types.PaymentTransition{
+		From: from,
+		To:   to,
+		At:   s.now(),
+	})
+}
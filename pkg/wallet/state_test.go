@@ -0,0 +1,158 @@
+package wallet
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shuhrat-shokirov/wallet/pkg/types"
+)
+
+func TestService_Complete(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Complete(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if payment.Status != types.PaymentStatusOk {
+		t.Errorf("invalid status, got %v", payment.Status)
+	}
+
+	err = svc.Complete(payment.ID)
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Error(err)
+	}
+
+	err = svc.Reject(payment.ID)
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) {
+		t.Error(err)
+		return
+	}
+
+	if transitionErr.From != types.PaymentStatusOk || transitionErr.To != types.PaymentStatusFail {
+		t.Errorf("invalid transition error, got %v", transitionErr)
+	}
+
+	if account.Balance != 900 {
+		t.Errorf("invalid balance, got %v", account.Balance)
+	}
+}
+
+func TestService_Reject_twice(t *testing.T) {
+	svc := &Service{}
+
+	account, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Deposit(account.ID, 1000)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	payment, err := svc.Pay(account.ID, 100, "auto")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Reject(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Reject(payment.ID)
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Error(err)
+	}
+
+	if account.Balance != 1000 {
+		t.Errorf("payment must be refunded once, got %v", account.Balance)
+	}
+
+	history, err := svc.PaymentHistory(payment.ID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	want := []types.PaymentStatus{types.PaymentStatusInProgress, types.PaymentStatusFail}
+	if len(history) != len(want) {
+		t.Errorf("invalid history, got %v", history)
+		return
+	}
+
+	for i, transition := range history {
+		if transition.To != want[i] {
+			t.Errorf("invalid transition %v, got %v, want %v", i, transition.To, want[i])
+		}
+	}
+}
+
+func TestService_Complete_transfer(t *testing.T) {
+	svc := &Service{}
+
+	from, err := svc.RegisterAccount("+992000000000")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	to, err := svc.RegisterAccount("+992000000001")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	from.Balance = 1000
+
+	debit, err := svc.Transfer(from.ID, to.ID, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = svc.Complete(debit.LinkedID)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if debit.Status != types.PaymentStatusOk {
+		t.Errorf("linked payment must be completed, got %v", debit.Status)
+	}
+
+	err = svc.Reject(debit.ID)
+	if !errors.Is(err, ErrIllegalTransition) {
+		t.Error(err)
+	}
+
+	if from.Balance != 900 || to.Balance != 100 {
+		t.Errorf("invalid balances, from %v, to %v", from.Balance, to.Balance)
+	}
+}